@@ -77,7 +77,7 @@ func gen(o string, logger zerolog.Logger) error {
 
 	var scopes []common.ScopeConfig
 
-	squery := []string{"^\\s*COMMAND\\=.*$"}
+	squery := []common.Query{{Pattern: "^\\s*COMMAND\\=.*$"}}
 	scopes = append(scopes, common.ScopeConfig{
 		Name:                 "example-1",
 		StartQuery:           "^\\W*BEGIN$",
@@ -1,9 +1,24 @@
 package cmd
 
 import (
+	"context"
+	"errors"
+
 	"github.com/spf13/cobra"
 )
 
+// Exit codes of gorex
+const (
+	// ExitOK means scan has finished and no fail condition is broken
+	ExitOK = 0
+	// ExitFailed means some fail condition (--fail-on) is broken
+	ExitFailed = 1
+	// ExitError means invalid arguments, configuration or failed scan
+	ExitError = 2
+	// ExitIncomplete means scan has been cancelled (results are incomplete)
+	ExitIncomplete = 3
+)
+
 var (
 	rootCmd = &cobra.Command{
 		Use:   `gorex`,
@@ -12,7 +27,36 @@ var (
 	}
 )
 
+// exitError is error returned by command with its own exit code
+type exitError struct {
+	code int
+	err  error
+}
+
+func (e *exitError) Error() string {
+	return e.err.Error()
+}
+
 // Execute executes the root command.
 func Execute() error {
 	return rootCmd.Execute()
 }
+
+// ExitCode returns exit code of error returned by Execute (ExitOK when error is nil)
+func ExitCode(err error) int {
+
+	if err == nil {
+		return ExitOK
+	}
+
+	var e *exitError
+	if errors.As(err, &e) {
+		return e.code
+	}
+
+	if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		return ExitIncomplete
+	}
+
+	return ExitError
+}
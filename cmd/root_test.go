@@ -0,0 +1,35 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+)
+
+// -----------------------------------------------------------------------------
+// functions
+// -----------------------------------------------------------------------------
+
+// TestExitCode checks exit code of every kind of error returned by command
+func TestExitCode(t *testing.T) {
+
+	tests := []struct {
+		name string
+		err  error
+		want int
+	}{
+		{name: "no error", err: nil, want: ExitOK},
+		{name: "failed gate", err: &exitError{code: ExitFailed, err: errors.New("Scan failed")}, want: ExitFailed},
+		{name: "wrapped failed gate", err: fmt.Errorf("wrapped: %w", &exitError{code: ExitFailed, err: errors.New("Scan failed")}), want: ExitFailed},
+		{name: "invalid configuration", err: errors.New("Invalid start query"), want: ExitError},
+		{name: "cancelled scan", err: context.Canceled, want: ExitIncomplete},
+		{name: "scan after deadline", err: fmt.Errorf("scan: %w", context.DeadlineExceeded), want: ExitIncomplete},
+	}
+
+	for _, tt := range tests {
+		if got := ExitCode(tt.err); got != tt.want {
+			t.Errorf("%v: exit code [%v], want [%v]", tt.name, got, tt.want)
+		}
+	}
+}
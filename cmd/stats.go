@@ -0,0 +1,154 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"syscall"
+
+	common "gorex/pkg/common"
+	"gorex/pkg/scanner"
+	"gorex/pkg/stats"
+	"gorex/pkg/utils"
+
+	"github.com/rs/zerolog"
+	"github.com/spf13/cobra"
+)
+
+var (
+	statsCmd = &cobra.Command{
+		Use:   "stats",
+		Short: "Show statistics of scan results (saved summary or live scan)",
+
+		RunE: func(cmd *cobra.Command, args []string) error {
+
+			data, err := cmd.Flags().GetString(fData)
+			if err != nil {
+				return err
+			}
+			recipe, err := cmd.Flags().GetString(fInput)
+			if err != nil {
+				return err
+			}
+			format, err := cmd.Flags().GetString(fFormat)
+			if err != nil {
+				return err
+			}
+			top, err := cmd.Flags().GetInt(fTop)
+			if err != nil {
+				return err
+			}
+			o, err := cmd.Flags().GetString(fOutput)
+			if err != nil {
+				return err
+			}
+			jobs, err := cmd.Flags().GetInt(fJobs)
+			if err != nil {
+				return err
+			}
+			trace, err := cmd.Flags().GetBool(fTrace)
+			if err != nil {
+				return err
+			}
+
+			// usage is not helpful when flags are read (invalid summary, configuration or failed scan)
+			cmd.SilenceUsage = true
+
+			return showStats(data, recipe, format, top, o, jobs, trace)
+		},
+	}
+)
+
+const (
+	fData   = "data"
+	fFormat = "format"
+	fTop    = "top"
+)
+
+// -----------------------------------------------------------------------------
+// functions
+// -----------------------------------------------------------------------------
+
+func showStats(data string, recipe string, format string, top int, o string, jobs int, trace bool) error {
+
+	if (data == "") == (recipe == "") {
+		return errors.New("Either scan summary (data) or scan configuration (input) should be set")
+	}
+
+	// statistics of cancelled live scan are shown too (they are flagged as incomplete)
+	summary, err := readSummary(data, recipe, jobs, trace)
+	if (err != nil) && (summary.Incomplete == false) {
+		return err
+	}
+
+	result := stats.Compute(summary, top)
+
+	var w io.Writer = os.Stdout
+	if o != "" {
+		f, err := os.Create(o)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		w = f
+	}
+
+	switch format {
+	case "table":
+		return result.WriteTable(w)
+	case "json":
+		return result.WriteJSON(w)
+	case "csv":
+		return result.WriteCSV(w)
+	default:
+		return fmt.Errorf("Unknown format [%v]. Should be table, json or csv", format)
+	}
+}
+
+// readSummary reads saved scan summary or scans folder of configuration with jobs workers (0 means workers of configuration).
+// Log of live scan is written only when trace is set, so it does not mix with statistics on stdout.
+func readSummary(data string, recipe string, jobs int, trace bool) (common.ScanSummary, error) {
+
+	if data != "" {
+		return common.ReadScanSummary(data)
+	}
+
+	cfg, err := common.ReadScopeConfiguration(recipe)
+	if err != nil {
+		return common.ScanSummary{}, err
+	}
+
+	if jobs > 0 {
+		cfg.Workers = jobs
+	}
+
+	logger := zerolog.Nop()
+	if trace == true {
+		logger = utils.CreateLogger("stats", trace)
+	}
+
+	sc, err := scanner.New(cfg, logger)
+	if err != nil {
+		return common.ScanSummary{}, err
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	return sc.ScanContext(ctx)
+}
+
+func init() {
+
+	statsCmd.Flags().StringP(fData, "d", "", "Scan summary (json file saved by scan --outputdata).")
+	statsCmd.Flags().StringP(fInput, "i", "", "Scan configuration (folder is scanned instead of reading scan summary).")
+	statsCmd.Flags().StringP(fFormat, "f", "table", "Output format: table, json or csv.")
+	statsCmd.Flags().IntP(fTop, "n", 10, "Number of top files and captured values (0 means all).")
+	statsCmd.Flags().StringP(fOutput, "o", "", "Output file (stdout when empty).")
+	statsCmd.Flags().BoolP(fTrace, "t", false, "Trace log of live scan.")
+	statsCmd.Flags().IntP(fJobs, "j", 0, "Number of files scanned in parallel by live scan (0 means number of CPUs).")
+	rootCmd.AddCommand(statsCmd)
+}
@@ -3,7 +3,7 @@ module gorex
 go 1.16
 
 require (
-	github.com/dlclark/regexp2 v1.4.0 // indirect
+	github.com/dlclark/regexp2 v1.4.0
 	github.com/rs/zerolog v1.20.0
 	github.com/spf13/cobra v1.1.1
 )
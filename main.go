@@ -3,10 +3,12 @@ package main
 import (
 	"gorex/cmd"
 	"log"
+	"os"
 )
 
 func main() {
 	if err := cmd.Execute(); err != nil {
-		log.Fatal(err)
+		log.Print(err)
+		os.Exit(cmd.ExitCode(err))
 	}
 }
@@ -0,0 +1,117 @@
+package common
+
+import (
+	"encoding/xml"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+)
+
+// -----------------------------------------------------------------------------
+// types
+// -----------------------------------------------------------------------------
+
+// Checkstyle XML report (format understood by IDEs and Jenkins warnings plugin)
+
+type checkstyleReport struct {
+	XMLName xml.Name         `xml:"checkstyle"`
+	Version string           `xml:"version,attr"`
+	Files   []checkstyleFile `xml:"file"`
+}
+
+type checkstyleFile struct {
+	Name   string            `xml:"name,attr"`
+	Errors []checkstyleError `xml:"error"`
+}
+
+type checkstyleError struct {
+	Line     int    `xml:"line,attr"`
+	Column   int    `xml:"column,attr,omitempty"`
+	Severity string `xml:"severity,attr"`
+	Message  string `xml:"message,attr"`
+	Source   string `xml:"source,attr"`
+}
+
+// -----------------------------------------------------------------------------
+// extensions
+// -----------------------------------------------------------------------------
+
+// WriteCheckstyle writes summary as Checkstyle XML report. Every match line is error in its line
+// (found scope without match lines is error in start line of scope). Source of error is "gorex." and name of scope,
+// severity of error is severity of scope (warning when scope has no severity).
+func (s ScanSummary) WriteCheckstyle(w io.Writer) error {
+
+	report := checkstyleReport{Version: "4.3"}
+
+	for _, f := range s.Summary {
+
+		file := checkstyleFile{Name: f.FileName}
+
+		for _, scope := range f.Scopes {
+
+			severity := scope.Severity
+			if severity == "" {
+				severity = SeverityWarning
+			}
+
+			if len(scope.Matches) == 0 {
+				file.Errors = append(file.Errors, checkstyleError{
+					Line:     scope.Started,
+					Severity: string(severity),
+					Message:  fmt.Sprintf("Scope [%v] in lines [%v..%v]", scope.Name, scope.Started, scope.Finished),
+					Source:   "gorex." + scope.Name,
+				})
+				continue
+			}
+
+			for _, m := range scope.Matches {
+				message := fmt.Sprintf("[%v] found in scope [%v] (lines [%v..%v]): %v", m.Query, scope.Name, scope.Started, scope.Finished, strings.TrimSpace(m.Line))
+				if m.Query == "" {
+					message = fmt.Sprintf("Found in scope [%v] (lines [%v..%v]): %v", scope.Name, scope.Started, scope.Finished, strings.TrimSpace(m.Line))
+				}
+				file.Errors = append(file.Errors, checkstyleError{
+					Line:     m.Index,
+					Column:   m.Column,
+					Severity: string(severity),
+					Message:  message,
+					Source:   "gorex." + scope.Name,
+				})
+			}
+		}
+
+		if f.Aborted == true {
+			file.Errors = append(file.Errors, checkstyleError{
+				Line:     1,
+				Severity: string(SeverityError),
+				Message:  "File aborted (too many timeouts or cancelled scan)",
+				Source:   "gorex",
+			})
+		}
+
+		report.Files = append(report.Files, file)
+	}
+
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return err
+	}
+
+	encoder := xml.NewEncoder(w)
+	encoder.Indent("", " ")
+	if err := encoder.Encode(report); err != nil {
+		return err
+	}
+	_, err := io.WriteString(w, "\n")
+	return err
+}
+
+// LogToCheckstyle generate Checkstyle XML report file (see WriteCheckstyle)
+func (s ScanSummary) LogToCheckstyle(p string) error {
+	f, err := os.Create(p)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	return s.WriteCheckstyle(f)
+}
@@ -0,0 +1,32 @@
+package common
+
+import (
+	"bytes"
+	"testing"
+)
+
+// -----------------------------------------------------------------------------
+// functions
+// -----------------------------------------------------------------------------
+
+// TestWriteCheckstyle compares Checkstyle report of clean scan and scan with findings with golden files
+func TestWriteCheckstyle(t *testing.T) {
+
+	tests := []struct {
+		golden  string
+		summary ScanSummary
+	}{
+		{golden: "checkstyle_clean.xml", summary: cleanSummary()},
+		{golden: "checkstyle_findings.xml", summary: findingsSummary()},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.golden, func(t *testing.T) {
+			var b bytes.Buffer
+			if err := tt.summary.WriteCheckstyle(&b); err != nil {
+				t.Fatal(err)
+			}
+			checkGolden(t, tt.golden, b.Bytes())
+		})
+	}
+}
@@ -5,8 +5,10 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
+	"io"
 	"io/ioutil"
 	"os"
+	"sort"
 	"text/template"
 	"time"
 )
@@ -30,58 +32,170 @@ const (
 	SearchQueryOperatorStrictOrder
 )
 
+// ScopeMode describes how end of scope is found
+type ScopeMode string
+
+const (
+	//ScopeModeRegex finishes scope on line which matches finishQuery (default mode)
+	ScopeModeRegex ScopeMode = "regex"
+	//ScopeModeBalanced finishes scope when delimiters opened after startQuery are balanced again
+	ScopeModeBalanced ScopeMode = "balanced"
+	//ScopeModeIndent finishes scope before first non blank line with indentation not greater than start line
+	ScopeModeIndent ScopeMode = "indent"
+	//ScopeModeFile uses whole file as one scope (startQuery and finishQuery are not used)
+	ScopeModeFile ScopeMode = "file"
+	//ScopeModeWindow finishes scope after number of lines (lines field) following start line
+	ScopeModeWindow ScopeMode = "window"
+	//ScopeModeBlank finishes scope before first blank line
+	ScopeModeBlank ScopeMode = "blank"
+)
+
+// Severity describes importance of found scope (used by --fail-on and reports)
+type Severity string
+
+const (
+	//SeverityInfo is informational finding
+	SeverityInfo Severity = "info"
+	//SeverityWarning is finding which should be reviewed
+	SeverityWarning Severity = "warning"
+	//SeverityError is forbidden finding
+	SeverityError Severity = "error"
+)
+
+// Severities contains every severity in ascending order of importance
+var Severities = []Severity{
+	SeverityInfo,
+	SeverityWarning,
+	SeverityError,
+}
+
+// DefaultTabWidth is number of spaces of tab used by ScopeModeIndent when tabWidth is not set
+const DefaultTabWidth = 4
+
 // Scan config structs :
 
-// ScopeConfig provides configuration of scan
+// BalancedConfig provides delimiters used by ScopeModeBalanced.
+// Delimiters inside string literals (which can not span lines) and comments are ignored.
+type BalancedConfig struct {
+	Open              string `json:"open" xml:"open,attr"`
+	Close             string `json:"close" xml:"close,attr"`
+	Quotes            string `json:"quotes" xml:"quotes,attr"`
+	LineComment       string `json:"lineComment" xml:"lineComment,attr"`
+	BlockCommentStart string `json:"blockCommentStart" xml:"blockCommentStart,attr"`
+	BlockCommentEnd   string `json:"blockCommentEnd" xml:"blockCommentEnd,attr"`
+}
+
+// DefaultBalancedConfig is used when scope in ScopeModeBalanced does not define delimiters (C-like braces)
+var DefaultBalancedConfig = BalancedConfig{
+	Open:              "{",
+	Close:             "}",
+	Quotes:            "\"'`",
+	LineComment:       "//",
+	BlockCommentStart: "/*",
+	BlockCommentEnd:   "*/",
+}
+
+// ScopeConfig provides configuration of scan.
+// StartQueryTimeout and FinishQueryTimeout (in milliseconds) override timeout of scan for start and finish query.
 type ScopeConfig struct {
 	Name                 string              `json:"name" xml:"name,attr"`
+	Severity             Severity            `json:"severity,omitempty" xml:"severity,attr,omitempty"`
 	StartQuery           string              `json:"startQuery" xml:"startQuery"`
 	FinishQuery          string              `json:"finishQuery" xml:"finishQuery"`
 	StartQueryCloseScope bool                `json:"startQueryCloseScope" xml:"startQueryCloseScope"`
-	SearchQuery          []string            `json:"searchQuery" xml:"searchQuery"`
+	StartQueryOptions    string              `json:"startQueryOptions,omitempty" xml:"startQueryOptions,omitempty"`
+	FinishQueryOptions   string              `json:"finishQueryOptions,omitempty" xml:"finishQueryOptions,omitempty"`
+	StartQueryEngine     QueryEngine         `json:"startQueryEngine,omitempty" xml:"startQueryEngine,omitempty"`
+	FinishQueryEngine    QueryEngine         `json:"finishQueryEngine,omitempty" xml:"finishQueryEngine,omitempty"`
+	StartQueryTimeout    int                 `json:"startQueryTimeout,omitempty" xml:"startQueryTimeout,omitempty"`
+	FinishQueryTimeout   int                 `json:"finishQueryTimeout,omitempty" xml:"finishQueryTimeout,omitempty"`
+	SearchQuery          []Query             `json:"searchQuery" xml:"searchQuery"`
 	SearchQueryMode      SearchQueryOperator `json:"searchQueryMode" xml:"searchQueryMode"`
+	SearchExpression     *QueryExpression    `json:"searchExpression,omitempty" xml:"searchExpression,omitempty"`
+	ExcludeQuery         []Query             `json:"excludeQuery,omitempty" xml:"excludeQuery,omitempty"`
+	MinMatches           int                 `json:"minMatches,omitempty" xml:"minMatches,attr,omitempty"`
+	MaxMatches           int                 `json:"maxMatches,omitempty" xml:"maxMatches,attr,omitempty"`
+	Mode                 ScopeMode           `json:"mode,omitempty" xml:"mode,attr,omitempty"`
+	Balanced             *BalancedConfig     `json:"balanced,omitempty" xml:"balanced,omitempty"`
+	TabWidth             int                 `json:"tabWidth,omitempty" xml:"tabWidth,attr,omitempty"`
+	Lines                int                 `json:"lines,omitempty" xml:"lines,attr,omitempty"`
+	Scopes               []ScopeConfig       `json:"scopes,omitempty" xml:"scopes,omitempty"`
 }
 
-// ScanConfig provides scan configuration
+// ScanConfig provides scan configuration.
+// MatchTimeout (in milliseconds, 0 means no limit) limits time of single match of regexp2 pattern.
+// File is aborted after MaxTimeouts lines which could not be matched in time (0 means never).
 type ScanConfig struct {
-	Folder string        `json:"folder" xml:"folder,attr"`
-	Filter string        `json:"filter" xml:"filder,attr"`
-	Scopes []ScopeConfig `json:"scopes" xml:"scopes"`
+	Folder       string        `json:"folder" xml:"folder,attr"`
+	Filter       string        `json:"filter" xml:"filder,attr"`
+	Workers      int           `json:"workers,omitempty" xml:"workers,attr,omitempty"`
+	MatchTimeout int           `json:"matchTimeout,omitempty" xml:"matchTimeout,attr,omitempty"`
+	MaxTimeouts  int           `json:"maxTimeouts,omitempty" xml:"maxTimeouts,attr,omitempty"`
+	Scopes       []ScopeConfig `json:"scopes" xml:"scopes"`
 }
 
 // Scan summary structs :
 
 // FileScopeSummary provides...
+// TimedOutLines contains lines which could not be matched in time (see ScanConfig.MatchTimeout).
+// Aborted file is not read to the end (too many timeouts or cancelled scan), it contains finished scopes only.
 type FileScopeSummary struct {
-	FileName   string         `json:"fileName" xml:"fileName,attr"`
-	Scopes     []ScopeSummary `json:"scopes" xml:"scopes"`
-	AllMatches int
+	FileName      string         `json:"fileName" xml:"fileName,attr"`
+	Scopes        []ScopeSummary `json:"scopes" xml:"scopes"`
+	AllMatches    int
+	TimedOutLines []int `json:"timedOutLines,omitempty" xml:"timedOutLines,omitempty"`
+	Aborted       bool  `json:"aborted,omitempty" xml:"aborted,attr,omitempty"`
 }
 
 // MatchLine provides...
+// Column and EndColumn are 1-based positions (in characters) of first character of match
+// and character after match. Match of multiline query ends in line EndIndex.
+// Captures contains values of named groups of search query.
 type MatchLine struct {
-	Line  string `json:"line" xml:"line,attr"`
-	Index int    `json:"index" xml:"index,attr"`
+	Line      string            `json:"line" xml:"line,attr"`
+	Index     int               `json:"index" xml:"index,attr"`
+	Query     string            `json:"query,omitempty" xml:"query,attr,omitempty"`
+	Column    int               `json:"column,omitempty" xml:"column,attr,omitempty"`
+	EndIndex  int               `json:"endIndex,omitempty" xml:"endIndex,attr,omitempty"`
+	EndColumn int               `json:"endColumn,omitempty" xml:"endColumn,attr,omitempty"`
+	Captures  map[string]string `json:"captures,omitempty" xml:"-"`
+}
+
+// ScopeParent provides parent scope of nested scope
+type ScopeParent struct {
+	Name    string `json:"name" xml:"name,attr"`
+	Started int    `json:"started" xml:"started,attr"`
 }
 
 // ScopeSummary provides...
 type ScopeSummary struct {
-	Name          string   `json:"name" xml:"name,attr"`
-	FileName      string   `json:"fileName" xml:"fileName,attr"`
-	Started       int      `json:"started" xml:"started,attr"`
-	Finished      int      `json:"finished" xml:"finished,attr"`
-	Content       []string `json:"content" xml:"content"`
+	Name          string            `json:"name" xml:"name,attr"`
+	FileName      string            `json:"fileName" xml:"fileName,attr"`
+	Started       int               `json:"started" xml:"started,attr"`
+	Finished      int               `json:"finished" xml:"finished,attr"`
+	Depth         int               `json:"depth" xml:"depth,attr"`
+	Severity      Severity          `json:"severity,omitempty" xml:"severity,attr,omitempty"`
+	Parents       []ScopeParent     `json:"parents,omitempty" xml:"parents,omitempty"`
+	Captures      map[string]string `json:"captures,omitempty" xml:"-"`
+	Reason        string            `json:"reason,omitempty" xml:"reason,omitempty"`
+	Counts        []QueryCount      `json:"counts,omitempty" xml:"counts,omitempty"`
+	Content       []string          `json:"content" xml:"content"`
 	ContentAsHTML []string
 	Matches       []MatchLine `json:"matches" xml:"matches"`
 }
 
 // ScanSummary provides...
+// Incomplete summary contains results found before scan has been cancelled.
+// FilesWithoutScopes contains sorted names of scanned files which are not in Summary (summary saved by
+// older version does not contain them).
 type ScanSummary struct {
-	Folder       string
-	Filter       string
-	CreationTime time.Time
-	Summary      []FileScopeSummary
-	ScanFiles    int
+	Folder             string
+	Filter             string
+	CreationTime       time.Time
+	Summary            []FileScopeSummary
+	FilesWithoutScopes []string `json:",omitempty"`
+	ScanFiles          int
+	Incomplete         bool
 }
 
 // ScopeSummaryWithConfig provides...
@@ -108,12 +222,28 @@ func ReadScopeConfiguration(configPath string) (ScanConfig, error) {
 
 	var scanConfig ScanConfig
 
-	if json.Unmarshal(byteValue, &scanConfig) != nil {
+	if err = json.Unmarshal(byteValue, &scanConfig); err != nil {
 		return ScanConfig{}, err
 	}
 	return scanConfig, nil
 }
 
+// ReadScanSummary reads scan summary saved by LogToFile (json format)
+func ReadScanSummary(summaryPath string) (ScanSummary, error) {
+
+	byteValue, err := ioutil.ReadFile(summaryPath)
+	if err != nil {
+		return ScanSummary{}, err
+	}
+
+	var scanSummary ScanSummary
+
+	if err = json.Unmarshal(byteValue, &scanSummary); err != nil {
+		return ScanSummary{}, err
+	}
+	return scanSummary, nil
+}
+
 // -----------------------------------------------------------------------------
 // extensions
 // -----------------------------------------------------------------------------
@@ -129,35 +259,262 @@ func (cfg ScanConfig) IsValid() error {
 		return errors.New("Empty filter")
 	}
 
+	if cfg.Workers < 0 {
+		return fmt.Errorf("Invalid number of workers [%v]", cfg.Workers)
+	}
+
+	if cfg.MatchTimeout < 0 {
+		return fmt.Errorf("Invalid match timeout [%v]", cfg.MatchTimeout)
+	}
+
+	if cfg.MaxTimeouts < 0 {
+		return fmt.Errorf("Invalid number of timeouts [%v]", cfg.MaxTimeouts)
+	}
+
 	if len(cfg.Scopes) == 0 {
 		return errors.New("Empty scopes")
 	}
 
 	for i, v := range cfg.Scopes {
-		if v.Name == "" {
-			return fmt.Errorf("Empty name of scope [%v]", i)
+		if err := v.isValid(fmt.Sprintf("%v", i), 0); err != nil {
+			return err
 		}
-		if len(v.SearchQuery) == 0 {
-			return fmt.Errorf("Empty search queries of scope [%v]", i)
+	}
+
+	return nil
+}
+
+// Rank returns position of severity in Severities (-1 when severity is unknown)
+func (s Severity) Rank() int {
+	for i, v := range Severities {
+		if v == s {
+			return i
 		}
+	}
+	return -1
+}
+
+// ScopeMode returns mode of scope (ScopeModeRegex when mode is not set)
+func (v ScopeConfig) ScopeMode() ScopeMode {
+	if v.Mode == "" {
+		return ScopeModeRegex
+	}
+	return v.Mode
+}
+
+// BalancedConfig returns delimiters of scope (DefaultBalancedConfig when delimiters are not set)
+func (v ScopeConfig) BalancedConfig() BalancedConfig {
+	if v.Balanced == nil {
+		return DefaultBalancedConfig
+	}
+	return *v.Balanced
+}
+
+// TabWidthOrDefault returns number of spaces of tab (DefaultTabWidth when tab width is not set)
+func (v ScopeConfig) TabWidthOrDefault() int {
+	if v.TabWidth == 0 {
+		return DefaultTabWidth
+	}
+	return v.TabWidth
+}
+
+// AcceptsMatches returns true when number of match lines of scope fulfils minMatches and maxMatches
+func (v ScopeConfig) AcceptsMatches(count int) bool {
+	return acceptsCount(count, v.MinMatches, v.MaxMatches)
+}
 
-		for j, q := range v.SearchQuery {
-			if q == "" {
-				return fmt.Errorf("Empty #%v search query of scope [%v]", j, i)
+// isValid check if ScopeConfig (and its child scopes) contains every required fields.
+// Path is index of scope (e.g. "0" or "0.1" for second child of first scope) and depth is level of nesting.
+func (v ScopeConfig) isValid(i string, depth int) error {
+
+	if v.Name == "" {
+		return fmt.Errorf("Empty name of scope [%v]", i)
+	}
+
+	if (v.Severity != "") && (v.Severity.Rank() < 0) {
+		return fmt.Errorf("Unknown severity [%v] of scope [%v]", v.Severity, i)
+	}
+
+	// scope with child scopes can be used as container only
+	if (len(v.SearchQuery) == 0) && (len(v.Scopes) == 0) {
+		return fmt.Errorf("Empty search queries of scope [%v]", i)
+	}
+
+	names := make(map[string]bool)
+	for j, q := range v.SearchQuery {
+		if q.Pattern == "" {
+			return fmt.Errorf("Empty #%v search query of scope [%v]", j, i)
+		}
+		if isValidLimits(q.Min, q.Max) == false {
+			return fmt.Errorf("Invalid limits [%v..%v] of #%v search query of scope [%v]", q.Min, q.Max, j, i)
+		}
+		if err := isValidQuery(q.Engine, q.Options); err != nil {
+			return fmt.Errorf("%v in #%v search query of scope [%v]", err, j, i)
+		}
+		if q.MatchTimeout < 0 {
+			return fmt.Errorf("Invalid match timeout [%v] of #%v search query of scope [%v]", q.MatchTimeout, j, i)
+		}
+		if q.Name != "" {
+			if names[q.Name] == true {
+				return fmt.Errorf("Duplicated name [%v] of search query of scope [%v]", q.Name, i)
 			}
+			names[q.Name] = true
 		}
+	}
+
+	if isValidLimits(v.MinMatches, v.MaxMatches) == false {
+		return fmt.Errorf("Invalid limits of matches [%v..%v] of scope [%v]", v.MinMatches, v.MaxMatches, i)
+	}
+
+	if v.SearchExpression != nil {
+		if err := v.SearchExpression.isValid(i, v.SearchQuery); err != nil {
+			return err
+		}
+	}
+
+	for j, q := range v.ExcludeQuery {
+		if q.Pattern == "" {
+			return fmt.Errorf("Empty #%v exclude query of scope [%v]", j, i)
+		}
+		if err := isValidQuery(q.Engine, q.Options); err != nil {
+			return fmt.Errorf("%v in #%v exclude query of scope [%v]", err, j, i)
+		}
+		if q.MatchTimeout < 0 {
+			return fmt.Errorf("Invalid match timeout [%v] of #%v exclude query of scope [%v]", q.MatchTimeout, j, i)
+		}
+	}
+
+	mode := v.ScopeMode()
+
+	if (mode != ScopeModeFile) && (v.StartQuery == "") {
+		return fmt.Errorf("Empty start query of scope [%v]", i)
+	}
+
+	if err := isValidQuery(v.StartQueryEngine, v.StartQueryOptions); err != nil {
+		return fmt.Errorf("%v in start query of scope [%v]", err, i)
+	}
+	if err := isValidQuery(v.FinishQueryEngine, v.FinishQueryOptions); err != nil {
+		return fmt.Errorf("%v in finish query of scope [%v]", err, i)
+	}
+	if v.StartQueryTimeout < 0 {
+		return fmt.Errorf("Invalid match timeout [%v] of start query of scope [%v]", v.StartQueryTimeout, i)
+	}
+	if v.FinishQueryTimeout < 0 {
+		return fmt.Errorf("Invalid match timeout [%v] of finish query of scope [%v]", v.FinishQueryTimeout, i)
+	}
 
-		if (v.StartQuery != "") && (v.FinishQuery == "") {
+	switch mode {
+	case ScopeModeRegex:
+		if v.FinishQuery == "" {
 			return fmt.Errorf("Empty finish query of scope [%v]", i)
 		}
-		if (v.StartQuery == "") && (v.FinishQuery != "") {
-			return fmt.Errorf("Empty start query of scope [%v]", i)
+	case ScopeModeBalanced:
+		b := v.BalancedConfig()
+		if (b.Open == "") || (b.Close == "") || (b.Open == b.Close) {
+			return fmt.Errorf("Invalid delimiters [%v][%v] of scope [%v]", b.Open, b.Close, i)
+		}
+		if (b.BlockCommentStart == "") != (b.BlockCommentEnd == "") {
+			return fmt.Errorf("Incomplete block comment delimiters of scope [%v]", i)
+		}
+	case ScopeModeIndent:
+		if v.TabWidth < 0 {
+			return fmt.Errorf("Invalid tab width [%v] of scope [%v]", v.TabWidth, i)
+		}
+	case ScopeModeFile:
+		if depth > 0 {
+			return fmt.Errorf("Mode [%v] can not be used by child scope [%v]", mode, i)
+		}
+	case ScopeModeWindow:
+		if v.Lines <= 0 {
+			return fmt.Errorf("Invalid number of lines [%v] of scope [%v]", v.Lines, i)
+		}
+	case ScopeModeBlank:
+	default:
+		return fmt.Errorf("Unknown mode [%v] of scope [%v]", v.Mode, i)
+	}
+
+	for j, c := range v.Scopes {
+		if err := c.isValid(fmt.Sprintf("%v.%v", i, j), depth+1); err != nil {
+			return err
 		}
 	}
 
 	return nil
 }
 
+// DistinctScopes returns scopes and their child scopes (depth first) with distinct names.
+// Only first scope of duplicated name is returned (found scopes are identified by name).
+func DistinctScopes(scopes []ScopeConfig) []ScopeConfig {
+	var result []ScopeConfig
+	names := make(map[string]bool)
+
+	var add func(scopes []ScopeConfig)
+	add = func(scopes []ScopeConfig) {
+		for _, sc := range scopes {
+			if names[sc.Name] == false {
+				names[sc.Name] = true
+				result = append(result, sc)
+			}
+			add(sc.Scopes)
+		}
+	}
+	add(scopes)
+
+	return result
+}
+
+// CaptureNames returns sorted names of named groups captured in match lines
+func CaptureNames(matches []MatchLine) []string {
+	set := make(map[string]bool)
+	for _, m := range matches {
+		for k := range m.Captures {
+			set[k] = true
+		}
+	}
+
+	var result []string
+	for k := range set {
+		result = append(result, k)
+	}
+	sort.Strings(result)
+
+	return result
+}
+
+// FilesWithScopes returns number of files with found scopes (summary contains files with timed out lines
+// or aborted files even without scopes)
+func (s ScanSummary) FilesWithScopes() int {
+	result := 0
+	for _, f := range s.Summary {
+		if len(f.Scopes) > 0 {
+			result++
+		}
+	}
+	return result
+}
+
+// DistinctCaptures returns sorted distinct values of named group captured in all match lines of summary
+func (s ScanSummary) DistinctCaptures(name string) []string {
+	set := make(map[string]bool)
+	for _, f := range s.Summary {
+		for _, sc := range f.Scopes {
+			for _, m := range sc.Matches {
+				if v, ok := m.Captures[name]; ok {
+					set[v] = true
+				}
+			}
+		}
+	}
+
+	var result []string
+	for v := range set {
+		result = append(result, v)
+	}
+	sort.Strings(result)
+
+	return result
+}
+
 // LogToFile writes summary to json file
 func (s ScanSummary) LogToFile(p string) error {
 	file, _ := json.MarshalIndent(s, "", " ")
@@ -165,9 +522,17 @@ func (s ScanSummary) LogToFile(p string) error {
 	return ioutil.WriteFile(p, file, 0644)
 }
 
-// LogToHTML generate html log file
+// WriteJSON writes summary in the same json format as LogToFile
+func (s ScanSummary) WriteJSON(w io.Writer) error {
+	file, _ := json.MarshalIndent(s, "", " ")
+
+	_, err := w.Write(file)
+	return err
+}
+
+// LogToHTML generate html log file. Report of incomplete scan is written even when no scopes were found before cancel.
 func (s ScanSummary) LogToHTML(p string) error {
-	if s.Summary == nil {
+	if (s.Summary == nil) && (s.Incomplete == false) {
 		return errors.New("scan summary does not contains any summaries")
 	}
 
@@ -177,11 +542,22 @@ func (s ScanSummary) LogToHTML(p string) error {
 	}
 	defer f.Close()
 
-	t, err := template.New("template").Parse(htmlPattern)
+	return s.WriteHTML(f)
+}
+
+// WriteHTML writes html report (see LogToHTML)
+func (s ScanSummary) WriteHTML(w io.Writer) error {
+	if (s.Summary == nil) && (s.Incomplete == false) {
+		return errors.New("scan summary does not contains any summaries")
+	}
+
+	t, err := template.New("template").Funcs(template.FuncMap{
+		"captureNames": CaptureNames,
+	}).Parse(htmlPattern)
 	if err != nil {
 		return err
 	}
-	err = t.Execute(f, s)
+	err = t.Execute(w, s)
 	if err != nil {
 		return err
 	}
@@ -0,0 +1,175 @@
+package common
+
+import (
+	"bytes"
+	"flag"
+	"io/ioutil"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+var update = flag.Bool("update", false, "update golden files of reports")
+
+// -----------------------------------------------------------------------------
+// functions
+// -----------------------------------------------------------------------------
+
+// checkGolden compares report with golden file of testdata folder (golden file is written with -update flag)
+func checkGolden(t *testing.T, name string, got []byte) {
+	t.Helper()
+
+	p := filepath.Join("testdata", name)
+	if *update == true {
+		if err := ioutil.WriteFile(p, got, 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	want, err := ioutil.ReadFile(p)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if bytes.Equal(got, want) == false {
+		t.Errorf("report differs from [%v]:\n%s\nwant:\n%s", p, got, want)
+	}
+}
+
+// reportConfig returns configuration of scan used by report tests
+func reportConfig() ScanConfig {
+	return ScanConfig{
+		Folder: "/src",
+		Scopes: []ScopeConfig{{
+			Name:     "transaction",
+			Severity: SeverityError,
+			Scopes:   []ScopeConfig{{Name: "command"}},
+		}},
+	}
+}
+
+// cleanSummary returns summary of scan which found no scopes
+func cleanSummary() ScanSummary {
+	return ScanSummary{
+		Folder:             "/src",
+		CreationTime:       time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC),
+		FilesWithoutScopes: []string{"/src/a.txt", "/src/b/c.txt"},
+		ScanFiles:          2,
+	}
+}
+
+// findingsSummary returns summary of scan which found scopes (with and without match lines) and aborted file
+func findingsSummary() ScanSummary {
+	return ScanSummary{
+		Folder:       "/src",
+		CreationTime: time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC),
+		Summary: []FileScopeSummary{
+			{
+				FileName: "/src/a.txt",
+				Scopes: []ScopeSummary{
+					{
+						Name: "transaction", FileName: "/src/a.txt", Started: 1, Finished: 4, Severity: SeverityError,
+						Reason:  "all 1 search queries found in 2 line(s)",
+						Content: []string{"BEGIN", "  COMMAND=ż1", "  COMMAND=2", "END"},
+						Matches: []MatchLine{
+							{Line: "  COMMAND=ż1", Index: 2, Query: "cmd", Column: 3, EndColumn: 13},
+							{Line: "  COMMAND=2", Index: 3, Query: "cmd", Column: 3, EndColumn: 12},
+						},
+					},
+					{
+						Name: "command", FileName: "/src/a.txt", Started: 2, Finished: 2, Depth: 1,
+						Parents: []ScopeParent{{Name: "transaction", Started: 1}},
+						Content: []string{"  COMMAND=ż1"},
+					},
+				},
+				AllMatches: 2,
+			},
+			{
+				FileName:      "/src/d.txt",
+				Scopes:        []ScopeSummary{},
+				TimedOutLines: []int{7},
+				Aborted:       true,
+			},
+		},
+		FilesWithoutScopes: []string{"/src/b/c.txt"},
+		ScanFiles:          3,
+	}
+}
+
+// TestWriteHTML_Parents checks that html report contains depth and chain of parent scopes of nested scope
+func TestWriteHTML_Parents(t *testing.T) {
+
+	summary := ScanSummary{Summary: []FileScopeSummary{{
+		FileName: "a.txt",
+		Scopes: []ScopeSummary{{
+			Name:     "g",
+			Started:  4,
+			Finished: 6,
+			Depth:    2,
+			Parents:  []ScopeParent{{Name: "p", Started: 2}, {Name: "c", Started: 3}},
+		}},
+	}}}
+
+	var b bytes.Buffer
+	if err := summary.WriteHTML(&b); err != nil {
+		t.Fatal(err)
+	}
+
+	want := "Parent scope(s) (depth <b>2</b>): <b>p</b>[2] &rsaquo; <b>c</b>[3]"
+	if strings.Contains(b.String(), want) == false {
+		t.Errorf("html report does not contain %q", want)
+	}
+}
+
+// TestWriteHTML_Incomplete checks that report of scan cancelled before first found scope is written and flagged
+func TestWriteHTML_Incomplete(t *testing.T) {
+
+	var b bytes.Buffer
+	if err := (ScanSummary{Incomplete: true}).WriteHTML(&b); err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(b.String(), "Scan has been cancelled") == false {
+		t.Errorf("html report of incomplete scan is not flagged")
+	}
+
+	if err := (ScanSummary{}).WriteHTML(&b); err == nil {
+		t.Errorf("html report of complete scan without summaries written, want error")
+	}
+}
+
+// TestScanSummary_DistinctCaptures checks that values of named group are de-duplicated across files and scopes
+func TestScanSummary_DistinctCaptures(t *testing.T) {
+
+	matches := func(values ...string) []MatchLine {
+		var result []MatchLine
+		for _, v := range values {
+			result = append(result, MatchLine{Captures: map[string]string{"value": v, "other": "o" + v}})
+		}
+		return result
+	}
+
+	summary := ScanSummary{Summary: []FileScopeSummary{
+		{FileName: "a.txt", Scopes: []ScopeSummary{{Matches: matches("b", "a")}, {Matches: matches("b")}}},
+		{FileName: "b.txt", Scopes: []ScopeSummary{{Matches: matches("c", "a", "")}, {Matches: []MatchLine{{Line: "no captures"}}}}},
+	}}
+
+	tests := []struct {
+		name string
+		want []string
+	}{
+		{name: "value", want: []string{"", "a", "b", "c"}},
+		{name: "other", want: []string{"o", "oa", "ob", "oc"}},
+		{name: "unknown", want: nil},
+	}
+
+	for _, tt := range tests {
+		if got := summary.DistinctCaptures(tt.name); reflect.DeepEqual(got, tt.want) == false {
+			t.Errorf("DistinctCaptures(%q) = %q, want %q", tt.name, got, tt.want)
+		}
+	}
+
+	if got, want := CaptureNames(summary.Summary[0].Scopes[0].Matches), []string{"other", "value"}; reflect.DeepEqual(got, want) == false {
+		t.Errorf("CaptureNames = %q, want %q", got, want)
+	}
+}
@@ -0,0 +1,172 @@
+package common
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+)
+
+// -----------------------------------------------------------------------------
+// types
+// -----------------------------------------------------------------------------
+
+// CSVOptions provides options of csv (or tsv) report.
+// Separator of fields is comma when it is not set. Columns selects columns of report (built-in columns
+// or names of captured groups), default are DefaultCSVColumns followed by every captured group.
+// Captured group named like built-in column is selected with CSVCapturePrefix (e.g. capture:line).
+type CSVOptions struct {
+	Separator rune
+	Columns   []string
+}
+
+// csvValue returns value of column for match line of scope
+type csvValue func(scope ScopeSummary, m MatchLine) string
+
+// csvColumns contains built-in columns of csv report
+var csvColumns = map[string]csvValue{
+	"file":      func(scope ScopeSummary, m MatchLine) string { return scope.FileName },
+	"scope":     func(scope ScopeSummary, m MatchLine) string { return scope.Name },
+	"severity":  func(scope ScopeSummary, m MatchLine) string { return string(scope.Severity) },
+	"started":   func(scope ScopeSummary, m MatchLine) string { return fmt.Sprint(scope.Started) },
+	"finished":  func(scope ScopeSummary, m MatchLine) string { return fmt.Sprint(scope.Finished) },
+	"query":     func(scope ScopeSummary, m MatchLine) string { return m.Query },
+	"index":     func(scope ScopeSummary, m MatchLine) string { return fmt.Sprint(m.Index) },
+	"column":    func(scope ScopeSummary, m MatchLine) string { return fmt.Sprint(m.Column) },
+	"endIndex":  func(scope ScopeSummary, m MatchLine) string { return fmt.Sprint(m.EndIndex) },
+	"endColumn": func(scope ScopeSummary, m MatchLine) string { return fmt.Sprint(m.EndColumn) },
+	"line":      func(scope ScopeSummary, m MatchLine) string { return m.Line },
+}
+
+// CSVCapturePrefix marks column of captured group (name of column without prefix is built-in column when such exists)
+const CSVCapturePrefix = "capture:"
+
+// DefaultCSVColumns contains columns of csv report when columns are not selected (captured groups are added)
+var DefaultCSVColumns = []string{"file", "scope", "started", "finished", "index", "line"}
+
+// -----------------------------------------------------------------------------
+// functions
+// -----------------------------------------------------------------------------
+
+// csvColumn returns value of column of csv report, column with CSVCapturePrefix or which is not built-in is captured group
+func csvColumn(column string) csvValue {
+	if strings.HasPrefix(column, CSVCapturePrefix) == true {
+		return csvCapture(strings.TrimPrefix(column, CSVCapturePrefix))
+	}
+	if v, ok := csvColumns[column]; ok {
+		return v
+	}
+	return csvCapture(column)
+}
+
+// csvCapture returns value of captured group of match line (or of start query of scope when match line does not capture it)
+func csvCapture(name string) csvValue {
+	return func(scope ScopeSummary, m MatchLine) string {
+		if v, ok := m.Captures[name]; ok {
+			return v
+		}
+		return scope.Captures[name]
+	}
+}
+
+// -----------------------------------------------------------------------------
+// extensions
+// -----------------------------------------------------------------------------
+
+// IsValid checks that every selected column is built-in column or name of captured group (captures are names
+// of named groups of queries of scan)
+func (o CSVOptions) IsValid(captures []string) error {
+
+	known := make(map[string]bool)
+	for _, name := range captures {
+		known[name] = true
+	}
+
+	for _, c := range o.Columns {
+		if strings.HasPrefix(c, CSVCapturePrefix) == true {
+			if known[strings.TrimPrefix(c, CSVCapturePrefix)] == false {
+				return fmt.Errorf("Unknown captured group of column [%v] of csv report. Should be one of %v", c, captures)
+			}
+			continue
+		}
+		if _, ok := csvColumns[c]; (ok == false) && (known[c] == false) {
+			var builtin []string
+			for name := range csvColumns {
+				builtin = append(builtin, name)
+			}
+			sort.Strings(builtin)
+			return fmt.Errorf("Unknown column [%v] of csv report. Should be one of %v or name of captured group %v", c, builtin, captures)
+		}
+	}
+	return nil
+}
+
+// captureNames returns sorted names of groups captured in match lines and start queries of found scopes
+func (s ScanSummary) captureNames() []string {
+	set := make(map[string]bool)
+	for _, f := range s.Summary {
+		for _, scope := range f.Scopes {
+			for name := range scope.Captures {
+				set[name] = true
+			}
+			for _, name := range CaptureNames(scope.Matches) {
+				set[name] = true
+			}
+		}
+	}
+
+	var result []string
+	for name := range set {
+		result = append(result, name)
+	}
+	sort.Strings(result)
+	return result
+}
+
+// WriteCSV writes one row per match line of found scopes (scope without match lines is not written).
+// First row contains names of columns. Fields which contain separator, quotes or new lines are quoted.
+func (s ScanSummary) WriteCSV(w io.Writer, options CSVOptions) error {
+
+	columns := options.Columns
+	if len(columns) == 0 {
+		columns = append([]string{}, DefaultCSVColumns...)
+		for _, name := range s.captureNames() {
+			if _, ok := csvColumns[name]; ok {
+				name = CSVCapturePrefix + name
+			}
+			columns = append(columns, name)
+		}
+	}
+
+	values := make([]csvValue, len(columns))
+	for i, c := range columns {
+		values[i] = csvColumn(c)
+	}
+
+	writer := csv.NewWriter(w)
+	if options.Separator != 0 {
+		writer.Comma = options.Separator
+	}
+
+	if err := writer.Write(columns); err != nil {
+		return err
+	}
+
+	row := make([]string, len(columns))
+	for _, f := range s.Summary {
+		for _, scope := range f.Scopes {
+			for _, m := range scope.Matches {
+				for i, v := range values {
+					row[i] = v(scope, m)
+				}
+				if err := writer.Write(row); err != nil {
+					return err
+				}
+			}
+		}
+	}
+
+	writer.Flush()
+	return writer.Error()
+}
@@ -0,0 +1,97 @@
+package common
+
+import (
+	"bytes"
+	"testing"
+)
+
+// -----------------------------------------------------------------------------
+// functions
+// -----------------------------------------------------------------------------
+
+// TestCSVOptions_IsValid checks that column is built-in column or name of captured group
+func TestCSVOptions_IsValid(t *testing.T) {
+
+	captures := []string{"line", "value"}
+
+	tests := []struct {
+		name    string
+		columns []string
+		want    string
+	}{
+		{name: "default columns", columns: nil, want: ""},
+		{name: "built-in columns", columns: []string{"file", "endColumn", "line"}, want: ""},
+		{name: "captured group", columns: []string{"file", "value"}, want: ""},
+		{name: "prefixed captured group", columns: []string{"capture:line", "capture:value"}, want: ""},
+		{
+			name:    "unknown column",
+			columns: []string{"file", "vlaue"},
+			want:    "Unknown column [vlaue] of csv report. Should be one of [column endColumn endIndex file finished index line query scope severity started] or name of captured group [line value]",
+		},
+		{
+			name:    "unknown prefixed captured group",
+			columns: []string{"capture:file"},
+			want:    "Unknown captured group of column [capture:file] of csv report. Should be one of [line value]",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := errorText(CSVOptions{Columns: tt.columns}.IsValid(captures)); got != tt.want {
+				t.Errorf("IsValid [%v], want [%v]", got, tt.want)
+			}
+		})
+	}
+}
+
+// TestWriteCSV checks selected columns, captured group named like built-in column and default columns
+func TestWriteCSV(t *testing.T) {
+
+	summary := ScanSummary{Summary: []FileScopeSummary{{
+		FileName: "a.txt",
+		Scopes: []ScopeSummary{{
+			FileName: "a.txt",
+			Name:     "s",
+			Started:  1,
+			Finished: 3,
+			Captures: map[string]string{"tag": "t"},
+			Matches: []MatchLine{
+				{Index: 2, Line: "x, 1", Captures: map[string]string{"line": "x", "value": "1"}},
+			},
+		}},
+	}}}
+
+	tests := []struct {
+		name    string
+		options CSVOptions
+		want    string
+	}{
+		{
+			name:    "selected columns",
+			options: CSVOptions{Columns: []string{"file", "index", "value", "tag"}},
+			want:    "file,index,value,tag\na.txt,2,1,t\n",
+		},
+		{
+			name:    "built-in column hides captured group",
+			options: CSVOptions{Columns: []string{"line", "capture:line"}},
+			want:    "line,capture:line\n\"x, 1\",x\n",
+		},
+		{
+			name:    "default columns",
+			options: CSVOptions{Separator: '\t'},
+			want:    "file\tscope\tstarted\tfinished\tindex\tline\tcapture:line\ttag\tvalue\na.txt\ts\t1\t3\t2\tx, 1\tx\tt\t1\n",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var b bytes.Buffer
+			if err := summary.WriteCSV(&b, tt.options); err != nil {
+				t.Fatal(err)
+			}
+			if b.String() != tt.want {
+				t.Errorf("WriteCSV = %q, want %q", b.String(), tt.want)
+			}
+		})
+	}
+}
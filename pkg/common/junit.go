@@ -0,0 +1,173 @@
+package common
+
+import (
+	"encoding/xml"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// -----------------------------------------------------------------------------
+// types
+// -----------------------------------------------------------------------------
+
+// JUnit XML report (format understood by Jenkins and most CI servers)
+
+type junitTestSuites struct {
+	XMLName  xml.Name         `xml:"testsuites"`
+	Name     string           `xml:"name,attr"`
+	Tests    int              `xml:"tests,attr"`
+	Failures int              `xml:"failures,attr"`
+	Errors   int              `xml:"errors,attr"`
+	Suites   []junitTestSuite `xml:"testsuite"`
+}
+
+type junitTestSuite struct {
+	Name      string          `xml:"name,attr"`
+	Tests     int             `xml:"tests,attr"`
+	Failures  int             `xml:"failures,attr"`
+	Errors    int             `xml:"errors,attr"`
+	Timestamp string          `xml:"timestamp,attr"`
+	Cases     []junitTestCase `xml:"testcase"`
+}
+
+type junitTestCase struct {
+	Name      string        `xml:"name,attr"`
+	ClassName string        `xml:"classname,attr"`
+	Failure   *junitProblem `xml:"failure,omitempty"`
+	Error     *junitProblem `xml:"error,omitempty"`
+}
+
+type junitProblem struct {
+	Message string `xml:"message,attr"`
+	Type    string `xml:"type,attr"`
+	Text    string `xml:",cdata"`
+}
+
+// -----------------------------------------------------------------------------
+// functions
+// -----------------------------------------------------------------------------
+
+// relativeFileName returns path of file relative to folder of scan (file name itself when file is outside of folder)
+func relativeFileName(folder string, fileName string) string {
+	rel, err := filepath.Rel(folder, fileName)
+	if (err != nil) || strings.HasPrefix(rel, "..") {
+		return filepath.ToSlash(fileName)
+	}
+	return filepath.ToSlash(rel)
+}
+
+// junitFailure describes every found scope of rule (scope with its lines and match lines)
+func junitFailure(scopes []ScopeSummary) *junitProblem {
+
+	var text strings.Builder
+	for _, scope := range scopes {
+		fmt.Fprintf(&text, "Scope [%v] in lines [%v..%v]", scope.Name, scope.Started, scope.Finished)
+		if scope.Reason != "" {
+			fmt.Fprintf(&text, " accepted because %v", scope.Reason)
+		}
+		text.WriteString("\n")
+		for _, m := range scope.Matches {
+			fmt.Fprintf(&text, "\t%v: %v\n", m.Index, strings.TrimSpace(m.Line))
+		}
+	}
+
+	severity := scopes[0].Severity
+	if severity == "" {
+		severity = SeverityWarning
+	}
+
+	return &junitProblem{
+		Message: fmt.Sprintf("[%v] scope(s) [%v] found", len(scopes), scopes[0].Name),
+		Type:    string(severity),
+		Text:    text.String(),
+	}
+}
+
+// -----------------------------------------------------------------------------
+// extensions
+// -----------------------------------------------------------------------------
+
+// WriteJUnit writes summary as JUnit XML report. Every scanned file is test suite, every distinct scope configuration
+// (see DistinctScopes) is test case which fails when scope is found in file (failure lists every found scope).
+// Test cases of files without scopes (see FilesWithoutScopes) pass. Aborted file has additional test case with error.
+func (s ScanSummary) WriteJUnit(w io.Writer, config ScanConfig) error {
+
+	rules := DistinctScopes(config.Scopes)
+
+	report := junitTestSuites{Name: "gorex"}
+
+	files := make([]FileScopeSummary, 0, len(s.Summary)+len(s.FilesWithoutScopes))
+	files = append(files, s.Summary...)
+	for _, name := range s.FilesWithoutScopes {
+		files = append(files, FileScopeSummary{FileName: name})
+	}
+
+	for _, f := range files {
+
+		fileName := relativeFileName(s.Folder, f.FileName)
+
+		found := make(map[string][]ScopeSummary)
+		for _, scope := range f.Scopes {
+			found[scope.Name] = append(found[scope.Name], scope)
+		}
+
+		suite := junitTestSuite{
+			Name:      fileName,
+			Timestamp: s.CreationTime.Format("2006-01-02T15:04:05"),
+		}
+
+		for _, rule := range rules {
+			c := junitTestCase{Name: rule.Name, ClassName: fileName}
+			if scopes := found[rule.Name]; len(scopes) > 0 {
+				c.Failure = junitFailure(scopes)
+				suite.Failures++
+			}
+			suite.Cases = append(suite.Cases, c)
+		}
+
+		if f.Aborted == true {
+			suite.Cases = append(suite.Cases, junitTestCase{
+				Name:      "scan",
+				ClassName: fileName,
+				Error: &junitProblem{
+					Message: "File aborted (too many timeouts or cancelled scan)",
+					Type:    "aborted",
+					Text:    fmt.Sprintf("Timed out lines: %v", f.TimedOutLines),
+				},
+			})
+			suite.Errors++
+		}
+
+		suite.Tests = len(suite.Cases)
+		report.Tests += suite.Tests
+		report.Failures += suite.Failures
+		report.Errors += suite.Errors
+		report.Suites = append(report.Suites, suite)
+	}
+
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return err
+	}
+
+	encoder := xml.NewEncoder(w)
+	encoder.Indent("", " ")
+	if err := encoder.Encode(report); err != nil {
+		return err
+	}
+	_, err := io.WriteString(w, "\n")
+	return err
+}
+
+// LogToJUnit generate JUnit XML report file (see WriteJUnit)
+func (s ScanSummary) LogToJUnit(p string, config ScanConfig) error {
+	f, err := os.Create(p)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	return s.WriteJUnit(f, config)
+}
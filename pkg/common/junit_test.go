@@ -0,0 +1,32 @@
+package common
+
+import (
+	"bytes"
+	"testing"
+)
+
+// -----------------------------------------------------------------------------
+// functions
+// -----------------------------------------------------------------------------
+
+// TestWriteJUnit compares JUnit report of clean scan and scan with findings with golden files
+func TestWriteJUnit(t *testing.T) {
+
+	tests := []struct {
+		golden  string
+		summary ScanSummary
+	}{
+		{golden: "junit_clean.xml", summary: cleanSummary()},
+		{golden: "junit_findings.xml", summary: findingsSummary()},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.golden, func(t *testing.T) {
+			var b bytes.Buffer
+			if err := tt.summary.WriteJUnit(&b, reportConfig()); err != nil {
+				t.Fatal(err)
+			}
+			checkGolden(t, tt.golden, b.Bytes())
+		})
+	}
+}
@@ -0,0 +1,291 @@
+package common
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+// -----------------------------------------------------------------------------
+// types
+// -----------------------------------------------------------------------------
+
+// ExpressionOperator describe types of nodes of QueryExpression
+type ExpressionOperator string
+
+const (
+	//ExpressionOperatorQuery is leaf which refers to named search query
+	ExpressionOperatorQuery ExpressionOperator = "query"
+	//ExpressionOperatorAnd is true when every node is true
+	ExpressionOperatorAnd ExpressionOperator = "and"
+	//ExpressionOperatorOr is true when any node is true
+	ExpressionOperatorOr ExpressionOperator = "or"
+	//ExpressionOperatorNot is true when its single node is false
+	ExpressionOperatorNot ExpressionOperator = "not"
+	//ExpressionOperatorSequence is true when queries (leaf nodes only) are found in given order
+	ExpressionOperatorSequence ExpressionOperator = "sequence"
+)
+
+// RegexOption is name of option of regular expression (options of query are separated by comma)
+type RegexOption string
+
+const (
+	//RegexOptionIgnoreCase matches letters case insensitive
+	RegexOptionIgnoreCase RegexOption = "ignoreCase"
+	//RegexOptionMultiline makes ^ and $ match at beginning and end of every line (useful in multiline queries)
+	RegexOptionMultiline RegexOption = "multiline"
+	//RegexOptionExplicitCapture captures named groups only
+	RegexOptionExplicitCapture RegexOption = "explicitCapture"
+	//RegexOptionRE2 makes syntax compatible with regexp package (RE2)
+	RegexOptionRE2 RegexOption = "re2"
+	//RegexOptionRightToLeft searches from right to left
+	RegexOptionRightToLeft RegexOption = "rightToLeft"
+	//RegexOptionECMAScript makes syntax compatible with ECMAScript
+	RegexOptionECMAScript RegexOption = "ecmaScript"
+)
+
+// RegexOptions contains every supported option of regular expression
+var RegexOptions = []RegexOption{
+	RegexOptionIgnoreCase,
+	RegexOptionMultiline,
+	RegexOptionExplicitCapture,
+	RegexOptionRE2,
+	RegexOptionRightToLeft,
+	RegexOptionECMAScript,
+}
+
+// QueryEngine is name of engine which matches pattern of query
+type QueryEngine string
+
+const (
+	//QueryEngineRegexp2 matches pattern with github.com/dlclark/regexp2 (default engine, .NET syntax)
+	QueryEngineRegexp2 QueryEngine = "regexp2"
+	//QueryEngineRegexp matches pattern with standard regexp package (RE2 syntax, linear time)
+	QueryEngineRegexp QueryEngine = "regexp"
+	//QueryEngineLiteral finds pattern as plain substring
+	QueryEngineLiteral QueryEngine = "literal"
+	//QueryEngineAhoCorasick finds any of literals separated by | in pattern (e.g. "ERROR|FATAL|PANIC")
+	QueryEngineAhoCorasick QueryEngine = "ahoCorasick"
+)
+
+// engineOptions contains options supported by engines (every option is supported by QueryEngineRegexp2)
+var engineOptions = map[QueryEngine][]RegexOption{
+	QueryEngineRegexp:      {RegexOptionIgnoreCase, RegexOptionMultiline, RegexOptionRE2},
+	QueryEngineLiteral:     {RegexOptionIgnoreCase},
+	QueryEngineAhoCorasick: {RegexOptionIgnoreCase},
+}
+
+// Query provides regular expression of search (or exclude) query.
+// In json file query can be written as string (pattern only) or as object.
+// Min and Max limit number of lines matched by search query (0 means no limit).
+// Multiline query is matched against whole content of scope (lines joined with new line) instead of every line.
+// Options (e.g. "ignoreCase,explicitCapture") are added to default options of query.
+// Engine selects matcher of pattern (QueryEngineRegexp2 when engine is not set).
+// MatchTimeout (in milliseconds) overrides timeout of scan for query.
+type Query struct {
+	Name         string      `json:"name,omitempty" xml:"name,attr,omitempty"`
+	Pattern      string      `json:"pattern" xml:",chardata"`
+	Min          int         `json:"min,omitempty" xml:"min,attr,omitempty"`
+	Max          int         `json:"max,omitempty" xml:"max,attr,omitempty"`
+	Multiline    bool        `json:"multiline,omitempty" xml:"multiline,attr,omitempty"`
+	Options      string      `json:"options,omitempty" xml:"options,attr,omitempty"`
+	Engine       QueryEngine `json:"engine,omitempty" xml:"engine,attr,omitempty"`
+	MatchTimeout int         `json:"matchTimeout,omitempty" xml:"matchTimeout,attr,omitempty"`
+}
+
+// QueryCount provides number of lines of scope matched by search query and limits of the query
+type QueryCount struct {
+	Query string `json:"query" xml:"query,attr"`
+	Count int    `json:"count" xml:"count,attr"`
+	Min   int    `json:"min,omitempty" xml:"min,attr,omitempty"`
+	Max   int    `json:"max,omitempty" xml:"max,attr,omitempty"`
+}
+
+// QueryExpression provides boolean expression of named search queries, e.g.
+// {"op":"or","nodes":[{"op":"and","nodes":[{"query":"A"},{"query":"B"}]},{"query":"C"}]}
+type QueryExpression struct {
+	Op    ExpressionOperator `json:"op,omitempty" xml:"op,attr,omitempty"`
+	Query string             `json:"query,omitempty" xml:"query,attr,omitempty"`
+	Nodes []QueryExpression  `json:"nodes,omitempty" xml:"nodes,omitempty"`
+}
+
+// -----------------------------------------------------------------------------
+// extensions
+// -----------------------------------------------------------------------------
+
+// UnmarshalJSON reads query from json string (pattern only) or json object
+func (q *Query) UnmarshalJSON(b []byte) error {
+
+	var pattern string
+	if err := json.Unmarshal(b, &pattern); err == nil {
+		*q = Query{Pattern: pattern}
+		return nil
+	}
+
+	type plainQuery Query
+	var p plainQuery
+	if err := json.Unmarshal(b, &p); err != nil {
+		return err
+	}
+
+	*q = Query(p)
+	return nil
+}
+
+// MarshalJSON writes query which has pattern only as json string
+func (q Query) MarshalJSON() ([]byte, error) {
+
+	if q.isPatternOnly() {
+		return json.Marshal(q.Pattern)
+	}
+
+	type plainQuery Query
+	return json.Marshal(plainQuery(q))
+}
+
+func (q Query) isPatternOnly() bool {
+	return q == Query{Pattern: q.Pattern}
+}
+
+// Label returns name of query (or pattern when query has no name)
+func (q Query) Label() string {
+	if q.Name != "" {
+		return q.Name
+	}
+	return q.Pattern
+}
+
+// Accepts returns true when number of matched lines fulfils limits of query (at least one line is required)
+func (q Query) Accepts(count int) bool {
+	return acceptsCount(count, q.Min, q.Max)
+}
+
+// acceptsCount returns true when count is positive and in range of min and max (0 means no limit)
+func acceptsCount(count int, min int, max int) bool {
+	return (count > 0) && (count >= min) && ((max == 0) || (count <= max))
+}
+
+// isValidLimits check if min and max are valid limits of number of lines
+func isValidLimits(min int, max int) bool {
+	return (min >= 0) && (max >= 0) && ((max == 0) || (max >= min))
+}
+
+// ParseRegexOptions returns options of comma separated list (e.g. "ignoreCase, rightToLeft")
+func ParseRegexOptions(options string) ([]RegexOption, error) {
+
+	var result []RegexOption
+	for _, o := range strings.Split(options, ",") {
+		o = strings.TrimSpace(o)
+		if o == "" {
+			continue
+		}
+
+		known := false
+		for _, r := range RegexOptions {
+			if strings.EqualFold(o, string(r)) == true {
+				result = append(result, r)
+				known = true
+				break
+			}
+		}
+		if known == false {
+			return nil, fmt.Errorf("Unknown regex option [%v]", o)
+		}
+	}
+
+	return result, nil
+}
+
+// OrDefault returns engine or QueryEngineRegexp2 when engine is not set
+func (e QueryEngine) OrDefault() QueryEngine {
+	if e == "" {
+		return QueryEngineRegexp2
+	}
+	return e
+}
+
+// isValidQuery check if engine is known and supports every option of query
+func isValidQuery(engine QueryEngine, options string) error {
+
+	parsed, err := ParseRegexOptions(options)
+	if err != nil {
+		return err
+	}
+
+	engine = engine.OrDefault()
+	if engine == QueryEngineRegexp2 {
+		return nil
+	}
+
+	supported, ok := engineOptions[engine]
+	if ok == false {
+		return fmt.Errorf("Unknown engine [%v]", engine)
+	}
+
+	for _, o := range parsed {
+		found := false
+		for _, s := range supported {
+			found = found || (o == s)
+		}
+		if found == false {
+			return fmt.Errorf("Regex option [%v] is not supported by engine [%v]", o, engine)
+		}
+	}
+
+	return nil
+}
+
+// Operator returns operator of node (ExpressionOperatorQuery when operator is not set)
+func (e QueryExpression) Operator() ExpressionOperator {
+	if e.Op == "" {
+		return ExpressionOperatorQuery
+	}
+	return e.Op
+}
+
+// isValid check if expression refers to existing queries only and every node has proper number of nodes
+func (e QueryExpression) isValid(i string, queries []Query) error {
+
+	switch e.Operator() {
+	case ExpressionOperatorQuery:
+		if e.Query == "" {
+			return fmt.Errorf("Empty query name in search expression of scope [%v]", i)
+		}
+		if len(e.Nodes) > 0 {
+			return fmt.Errorf("Query [%v] can not contain nodes in search expression of scope [%v]", e.Query, i)
+		}
+		for _, q := range queries {
+			if q.Name == e.Query {
+				return nil
+			}
+		}
+		return fmt.Errorf("Unknown query [%v] in search expression of scope [%v]", e.Query, i)
+	case ExpressionOperatorAnd, ExpressionOperatorOr:
+		if len(e.Nodes) == 0 {
+			return fmt.Errorf("Empty [%v] node in search expression of scope [%v]", e.Op, i)
+		}
+	case ExpressionOperatorNot:
+		if len(e.Nodes) != 1 {
+			return fmt.Errorf("Node [%v] should contain exactly one node in search expression of scope [%v]", e.Op, i)
+		}
+	case ExpressionOperatorSequence:
+		if len(e.Nodes) == 0 {
+			return fmt.Errorf("Empty [%v] node in search expression of scope [%v]", e.Op, i)
+		}
+		for _, n := range e.Nodes {
+			if n.Operator() != ExpressionOperatorQuery {
+				return fmt.Errorf("Node [%v] can contain queries only in search expression of scope [%v]", e.Op, i)
+			}
+		}
+	default:
+		return fmt.Errorf("Unknown operator [%v] in search expression of scope [%v]", e.Op, i)
+	}
+
+	for _, n := range e.Nodes {
+		if err := n.isValid(i, queries); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
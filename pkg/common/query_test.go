@@ -0,0 +1,212 @@
+package common
+
+import (
+	"reflect"
+	"testing"
+)
+
+// -----------------------------------------------------------------------------
+// functions
+// -----------------------------------------------------------------------------
+
+// errorText returns text of error (empty string when error is nil)
+func errorText(err error) string {
+	if err == nil {
+		return ""
+	}
+	return err.Error()
+}
+
+// TestQuery_Accepts checks limits of number of lines matched by query (and by scope)
+func TestQuery_Accepts(t *testing.T) {
+
+	tests := []struct {
+		count, min, max int
+		want            bool
+	}{
+		{count: 0, want: false},
+		{count: 1, want: true},
+		{count: 100, want: true},
+		{count: 0, min: 0, max: 2, want: false},
+		{count: 1, min: 2, want: false},
+		{count: 2, min: 2, want: true},
+		{count: 3, max: 2, want: false},
+		{count: 2, max: 2, want: true},
+		{count: 2, min: 2, max: 2, want: true},
+		{count: 1, min: 2, max: 3, want: false},
+		{count: 4, min: 2, max: 3, want: false},
+	}
+
+	for _, tt := range tests {
+		if got := (Query{Min: tt.min, Max: tt.max}).Accepts(tt.count); got != tt.want {
+			t.Errorf("query [%v..%v] accepts [%v] = %v, want %v", tt.min, tt.max, tt.count, got, tt.want)
+		}
+		if got := (ScopeConfig{MinMatches: tt.min, MaxMatches: tt.max}).AcceptsMatches(tt.count); got != tt.want {
+			t.Errorf("scope [%v..%v] accepts [%v] = %v, want %v", tt.min, tt.max, tt.count, got, tt.want)
+		}
+	}
+}
+
+// TestIsValidLimits checks valid and invalid limits of number of lines
+func TestIsValidLimits(t *testing.T) {
+
+	tests := []struct {
+		min, max int
+		want     bool
+	}{
+		{min: 0, max: 0, want: true},
+		{min: 2, max: 0, want: true},
+		{min: 0, max: 2, want: true},
+		{min: 2, max: 2, want: true},
+		{min: 3, max: 2, want: false},
+		{min: -1, max: 0, want: false},
+		{min: 0, max: -1, want: false},
+	}
+
+	for _, tt := range tests {
+		if got := isValidLimits(tt.min, tt.max); got != tt.want {
+			t.Errorf("isValidLimits(%v, %v) = %v, want %v", tt.min, tt.max, got, tt.want)
+		}
+	}
+}
+
+// TestScopeConfig_IsValid_Limits checks errors of invalid limits of search query and scope
+func TestScopeConfig_IsValid_Limits(t *testing.T) {
+
+	tests := []struct {
+		name  string
+		scope ScopeConfig
+		want  string
+	}{
+		{
+			name:  "max of query less than min",
+			scope: ScopeConfig{SearchQuery: []Query{{Pattern: "a"}, {Pattern: "b", Min: 3, Max: 2}}},
+			want:  "Invalid limits [3..2] of #1 search query of scope [0]",
+		},
+		{
+			name:  "negative min of query",
+			scope: ScopeConfig{SearchQuery: []Query{{Pattern: "a", Min: -1}}},
+			want:  "Invalid limits [-1..0] of #0 search query of scope [0]",
+		},
+		{
+			name:  "max matches of scope less than min",
+			scope: ScopeConfig{SearchQuery: []Query{{Pattern: "a"}}, MinMatches: 2, MaxMatches: 1},
+			want:  "Invalid limits of matches [2..1] of scope [0]",
+		},
+		{
+			name:  "negative max matches of scope",
+			scope: ScopeConfig{SearchQuery: []Query{{Pattern: "a"}}, MaxMatches: -1},
+			want:  "Invalid limits of matches [0..-1] of scope [0]",
+		},
+		{
+			name:  "valid limits",
+			scope: ScopeConfig{SearchQuery: []Query{{Pattern: "a", Min: 1, Max: 1}}, MinMatches: 1, MaxMatches: 5},
+			want:  "",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+
+			sc := tt.scope
+			sc.Name, sc.StartQuery, sc.FinishQuery = "s", "BEGIN", "END"
+
+			err := sc.isValid("0", 0)
+			if got := errorText(err); got != tt.want {
+				t.Errorf("error [%v], want [%v]", got, tt.want)
+			}
+		})
+	}
+}
+
+// TestParseRegexOptions checks list of options (names are case insensitive) and unknown options
+func TestParseRegexOptions(t *testing.T) {
+
+	tests := []struct {
+		options string
+		want    []RegexOption
+		wantErr string
+	}{
+		{options: "", want: nil},
+		{options: "ignoreCase", want: []RegexOption{RegexOptionIgnoreCase}},
+		{options: " IGNORECASE , rightToLeft,,", want: []RegexOption{RegexOptionIgnoreCase, RegexOptionRightToLeft}},
+		{options: "ignoreCase,global", wantErr: "Unknown regex option [global]"},
+	}
+
+	for _, tt := range tests {
+		got, err := ParseRegexOptions(tt.options)
+		if errorText(err) != tt.wantErr {
+			t.Errorf("ParseRegexOptions(%q) error [%v], want [%v]", tt.options, err, tt.wantErr)
+		}
+		if reflect.DeepEqual(got, tt.want) == false {
+			t.Errorf("ParseRegexOptions(%q) = %v, want %v", tt.options, got, tt.want)
+		}
+	}
+}
+
+// TestIsValidQuery checks options supported by every engine
+func TestIsValidQuery(t *testing.T) {
+
+	tests := []struct {
+		engine  QueryEngine
+		options string
+		want    string
+	}{
+		{engine: "", options: "ignoreCase,multiline,explicitCapture,re2,rightToLeft,ecmaScript", want: ""},
+		{engine: QueryEngineRegexp2, options: "rightToLeft", want: ""},
+		{engine: QueryEngineRegexp, options: "ignoreCase,multiline,re2", want: ""},
+		{engine: QueryEngineRegexp, options: "rightToLeft", want: "Regex option [rightToLeft] is not supported by engine [regexp]"},
+		{engine: QueryEngineRegexp, options: "explicitCapture", want: "Regex option [explicitCapture] is not supported by engine [regexp]"},
+		{engine: QueryEngineLiteral, options: "ignoreCase", want: ""},
+		{engine: QueryEngineLiteral, options: "multiline", want: "Regex option [multiline] is not supported by engine [literal]"},
+		{engine: QueryEngineAhoCorasick, options: "ignoreCase", want: ""},
+		{engine: QueryEngineAhoCorasick, options: "ecmaScript", want: "Regex option [ecmaScript] is not supported by engine [ahoCorasick]"},
+		{engine: QueryEngineRegexp, options: "unknown", want: "Unknown regex option [unknown]"},
+		{engine: "pcre", options: "", want: "Unknown engine [pcre]"},
+	}
+
+	for _, tt := range tests {
+		if got := errorText(isValidQuery(tt.engine, tt.options)); got != tt.want {
+			t.Errorf("isValidQuery(%q, %q) error [%v], want [%v]", tt.engine, tt.options, got, tt.want)
+		}
+	}
+}
+
+// TestScopeConfig_IsValid_Options checks that error of unsupported option names query and scope
+func TestScopeConfig_IsValid_Options(t *testing.T) {
+
+	sc := ScopeConfig{Name: "s", StartQuery: "BEGIN", FinishQuery: "END", SearchQuery: []Query{{Pattern: "a", Engine: QueryEngineRegexp, Options: "rightToLeft"}}}
+
+	want := "Regex option [rightToLeft] is not supported by engine [regexp] in #0 search query of scope [0]"
+	if got := errorText(sc.isValid("0", 0)); got != want {
+		t.Errorf("error [%v], want [%v]", got, want)
+	}
+}
+
+// TestScopeConfig_IsValid_Timeouts checks that timeouts of queries are not negative
+func TestScopeConfig_IsValid_Timeouts(t *testing.T) {
+
+	tests := []struct {
+		name  string
+		scope func(sc *ScopeConfig)
+		want  string
+	}{
+		{name: "search query", scope: func(sc *ScopeConfig) { sc.SearchQuery[0].MatchTimeout = -1 }, want: "Invalid match timeout [-1] of #0 search query of scope [0]"},
+		{name: "exclude query", scope: func(sc *ScopeConfig) { sc.ExcludeQuery = []Query{{Pattern: "b", MatchTimeout: -2}} }, want: "Invalid match timeout [-2] of #0 exclude query of scope [0]"},
+		{name: "start query", scope: func(sc *ScopeConfig) { sc.StartQueryTimeout = -3 }, want: "Invalid match timeout [-3] of start query of scope [0]"},
+		{name: "finish query", scope: func(sc *ScopeConfig) { sc.FinishQueryTimeout = -4 }, want: "Invalid match timeout [-4] of finish query of scope [0]"},
+		{name: "valid timeouts", scope: func(sc *ScopeConfig) { sc.StartQueryTimeout, sc.FinishQueryTimeout = 10, 20 }, want: ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+
+			sc := ScopeConfig{Name: "s", StartQuery: "BEGIN", FinishQuery: "END", SearchQuery: []Query{{Pattern: "a"}}}
+			tt.scope(&sc)
+
+			if got := errorText(sc.isValid("0", 0)); got != tt.want {
+				t.Errorf("error [%v], want [%v]", got, tt.want)
+			}
+		})
+	}
+}
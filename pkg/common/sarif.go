@@ -0,0 +1,329 @@
+package common
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// -----------------------------------------------------------------------------
+// types
+// -----------------------------------------------------------------------------
+
+// SARIF 2.1.0 log (only properties used by gorex), see https://docs.oasis-open.org/sarif/sarif/v2.1.0/
+
+type sarifLog struct {
+	Schema  string     `json:"$schema"`
+	Version string     `json:"version"`
+	Runs    []sarifRun `json:"runs"`
+}
+
+type sarifRun struct {
+	Tool               sarifTool                        `json:"tool"`
+	Invocations        []sarifInvocation                `json:"invocations"`
+	OriginalURIBaseIDs map[string]sarifArtifactLocation `json:"originalUriBaseIds,omitempty"`
+	Artifacts          []sarifArtifact                  `json:"artifacts,omitempty"`
+	ColumnKind         string                           `json:"columnKind"`
+	Results            []sarifResult                    `json:"results"`
+}
+
+type sarifTool struct {
+	Driver sarifDriver `json:"driver"`
+}
+
+type sarifDriver struct {
+	Name  string      `json:"name"`
+	Rules []sarifRule `json:"rules"`
+}
+
+type sarifRule struct {
+	ID                   string              `json:"id"`
+	Name                 string              `json:"name"`
+	ShortDescription     sarifMessage        `json:"shortDescription"`
+	FullDescription      sarifMessage        `json:"fullDescription"`
+	DefaultConfiguration *sarifConfiguration `json:"defaultConfiguration,omitempty"`
+}
+
+type sarifConfiguration struct {
+	Level string `json:"level"`
+}
+
+type sarifInvocation struct {
+	ExecutionSuccessful        bool                `json:"executionSuccessful"`
+	ToolExecutionNotifications []sarifNotification `json:"toolExecutionNotifications,omitempty"`
+}
+
+type sarifNotification struct {
+	Level     string          `json:"level"`
+	Message   sarifMessage    `json:"message"`
+	Locations []sarifLocation `json:"locations,omitempty"`
+}
+
+type sarifArtifact struct {
+	Location sarifArtifactLocation `json:"location"`
+	Roles    []string              `json:"roles"`
+	Contents *sarifMessage         `json:"contents,omitempty"`
+}
+
+type sarifResult struct {
+	RuleID           string            `json:"ruleId"`
+	RuleIndex        int               `json:"ruleIndex"`
+	Level            string            `json:"level"`
+	Message          sarifMessage      `json:"message"`
+	Locations        []sarifLocation   `json:"locations"`
+	RelatedLocations []sarifLocation   `json:"relatedLocations,omitempty"`
+	Properties       map[string]string `json:"properties,omitempty"`
+}
+
+type sarifMessage struct {
+	Text string `json:"text"`
+}
+
+type sarifLocation struct {
+	ID               int                   `json:"id,omitempty"`
+	PhysicalLocation sarifPhysicalLocation `json:"physicalLocation"`
+	Message          *sarifMessage         `json:"message,omitempty"`
+}
+
+type sarifPhysicalLocation struct {
+	ArtifactLocation sarifArtifactLocation `json:"artifactLocation"`
+	Region           *sarifRegion          `json:"region,omitempty"`
+}
+
+type sarifArtifactLocation struct {
+	URI       string `json:"uri"`
+	URIBaseID string `json:"uriBaseId,omitempty"`
+}
+
+type sarifRegion struct {
+	StartLine   int `json:"startLine"`
+	StartColumn int `json:"startColumn,omitempty"`
+	EndLine     int `json:"endLine,omitempty"`
+	EndColumn   int `json:"endColumn,omitempty"`
+}
+
+// sarifRoot is base of file locations (folder of scan)
+const sarifRoot = "SRCROOT"
+
+// sarifColumnKind is unit of columns of regions (columns of matches are counted in characters, default unit
+// of SARIF is UTF-16 code unit)
+const sarifColumnKind = "unicodeCodePoints"
+
+// sarifLevels maps severity of scope to level of result (scope without severity is reported as warning)
+var sarifLevels = map[Severity]string{
+	SeverityInfo:    "note",
+	SeverityWarning: "warning",
+	SeverityError:   "error",
+}
+
+// -----------------------------------------------------------------------------
+// functions
+// -----------------------------------------------------------------------------
+
+// sarifRules returns rule of every distinct scope configuration (see DistinctScopes) and index of rule by name
+func sarifRules(scopes []ScopeConfig) ([]sarifRule, map[string]int) {
+	rules := []sarifRule{}
+	index := make(map[string]int)
+	for _, sc := range DistinctScopes(scopes) {
+		rule := sarifRule{
+			ID:               sc.Name,
+			Name:             sc.Name,
+			ShortDescription: sarifMessage{Text: fmt.Sprintf("Scope [%v]", sc.Name)},
+			FullDescription:  sarifMessage{Text: sarifDescription(sc)},
+		}
+		if sc.Severity != "" {
+			rule.DefaultConfiguration = &sarifConfiguration{Level: sarifLevels[sc.Severity]}
+		}
+		index[sc.Name] = len(rules)
+		rules = append(rules, rule)
+	}
+	return rules, index
+}
+
+// sarifDescription describes queries of scope
+func sarifDescription(sc ScopeConfig) string {
+
+	var queries []string
+	for _, q := range sc.SearchQuery {
+		queries = append(queries, q.Pattern)
+	}
+
+	text := fmt.Sprintf("Scope [%v] (mode %v)", sc.Name, sc.ScopeMode())
+	if sc.StartQuery != "" {
+		text += fmt.Sprintf(" starts with [%v]", sc.StartQuery)
+	}
+	if sc.FinishQuery != "" {
+		text += fmt.Sprintf(" finishes with [%v]", sc.FinishQuery)
+	}
+	if len(queries) > 0 {
+		text += fmt.Sprintf(" and contains [%v]", strings.Join(queries, "], ["))
+	}
+	return text
+}
+
+// sarifFileLocation returns location of file relative to folder of scan (absolute uri when file is outside of folder)
+func sarifFileLocation(folder string, fileName string) sarifArtifactLocation {
+	rel, err := filepath.Rel(folder, fileName)
+	if (err != nil) || strings.HasPrefix(rel, "..") {
+		return sarifArtifactLocation{URI: sarifFileURI(fileName)}
+	}
+	return sarifArtifactLocation{URI: filepath.ToSlash(rel), URIBaseID: sarifRoot}
+}
+
+// sarifFileURI returns file uri of path (absolute path is used)
+func sarifFileURI(p string) string {
+	if abs, err := filepath.Abs(p); err == nil {
+		p = abs
+	}
+	p = filepath.ToSlash(p)
+	if strings.HasPrefix(p, "/") == false {
+		p = "/" + p
+	}
+	return "file://" + p
+}
+
+// sarifLocationOf returns location of lines (and columns when they are known) of file
+func sarifLocationOf(file sarifArtifactLocation, startLine int, startColumn int, endLine int, endColumn int) sarifLocation {
+	region := &sarifRegion{
+		StartLine:   startLine,
+		StartColumn: startColumn,
+		EndLine:     endLine,
+		EndColumn:   endColumn,
+	}
+	if region.EndLine < region.StartLine {
+		region.EndLine = region.StartLine
+	}
+	return sarifLocation{PhysicalLocation: sarifPhysicalLocation{ArtifactLocation: file, Region: region}}
+}
+
+// -----------------------------------------------------------------------------
+// extensions
+// -----------------------------------------------------------------------------
+
+// WriteSARIF writes summary as SARIF 2.1.0 log. Every scope configuration is rule, every match line is result
+// (found scope without match lines is result itself). Recipe file (when set) is included as tool configuration.
+func (s ScanSummary) WriteSARIF(w io.Writer, config ScanConfig, recipe string) error {
+
+	rules, index := sarifRules(config.Scopes)
+
+	run := sarifRun{
+		Tool:       sarifTool{Driver: sarifDriver{Name: "gorex", Rules: rules}},
+		ColumnKind: sarifColumnKind,
+		Results:    []sarifResult{},
+		OriginalURIBaseIDs: map[string]sarifArtifactLocation{
+			sarifRoot: {URI: strings.TrimSuffix(sarifFileURI(s.Folder), "/") + "/"},
+		},
+	}
+
+	if recipe != "" {
+		content, err := ioutil.ReadFile(recipe)
+		if err != nil {
+			return err
+		}
+		run.Artifacts = append(run.Artifacts, sarifArtifact{
+			Location: sarifArtifactLocation{URI: sarifFileURI(recipe)},
+			Roles:    []string{"toolSpecifiedConfiguration"},
+			Contents: &sarifMessage{Text: string(content)},
+		})
+	}
+
+	invocation := sarifInvocation{ExecutionSuccessful: s.Incomplete == false}
+
+	for _, f := range s.Summary {
+
+		file := sarifFileLocation(s.Folder, f.FileName)
+
+		for _, line := range f.TimedOutLines {
+			invocation.ToolExecutionNotifications = append(invocation.ToolExecutionNotifications, sarifNotification{
+				Level:     "warning",
+				Message:   sarifMessage{Text: "Line could not be matched in time"},
+				Locations: []sarifLocation{sarifLocationOf(file, line, 0, 0, 0)},
+			})
+		}
+		if f.Aborted == true {
+			invocation.ToolExecutionNotifications = append(invocation.ToolExecutionNotifications, sarifNotification{
+				Level:     "warning",
+				Message:   sarifMessage{Text: "File aborted (too many timeouts or cancelled scan)"},
+				Locations: []sarifLocation{{PhysicalLocation: sarifPhysicalLocation{ArtifactLocation: file}}},
+			})
+		}
+
+		for _, scope := range f.Scopes {
+
+			ruleIndex, ok := index[scope.Name]
+			if ok == false {
+				return fmt.Errorf("Scope [%v] of file [%v] is not configured", scope.Name, f.FileName)
+			}
+
+			level, ok := sarifLevels[scope.Severity]
+			if ok == false {
+				level = "warning"
+			}
+
+			scopeLocation := sarifLocationOf(file, scope.Started, 0, scope.Finished, 0)
+			scopeText := fmt.Sprintf("Scope [%v] in lines [%v..%v]", scope.Name, scope.Started, scope.Finished)
+			if scope.Reason != "" {
+				scopeText += fmt.Sprintf(" accepted because %v", scope.Reason)
+			}
+
+			if len(scope.Matches) == 0 {
+				run.Results = append(run.Results, sarifResult{
+					RuleID:     scope.Name,
+					RuleIndex:  ruleIndex,
+					Level:      level,
+					Message:    sarifMessage{Text: scopeText},
+					Locations:  []sarifLocation{scopeLocation},
+					Properties: scope.Captures,
+				})
+				continue
+			}
+
+			related := scopeLocation
+			related.ID = 1
+			related.Message = &sarifMessage{Text: scopeText}
+
+			for _, m := range scope.Matches {
+				text := fmt.Sprintf("[%v] found in scope [%v]: %v", m.Query, scope.Name, strings.TrimSpace(m.Line))
+				if m.Query == "" {
+					text = fmt.Sprintf("Found in scope [%v]: %v", scope.Name, strings.TrimSpace(m.Line))
+				}
+				run.Results = append(run.Results, sarifResult{
+					RuleID:           scope.Name,
+					RuleIndex:        ruleIndex,
+					Level:            level,
+					Message:          sarifMessage{Text: text},
+					Locations:        []sarifLocation{sarifLocationOf(file, m.Index, m.Column, m.EndIndex, m.EndColumn)},
+					RelatedLocations: []sarifLocation{related},
+					Properties:       m.Captures,
+				})
+			}
+		}
+	}
+
+	run.Invocations = []sarifInvocation{invocation}
+
+	log := sarifLog{
+		Schema:  "https://json.schemastore.org/sarif-2.1.0.json",
+		Version: "2.1.0",
+		Runs:    []sarifRun{run},
+	}
+
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", " ")
+	return encoder.Encode(log)
+}
+
+// LogToSARIF generate SARIF log file (see WriteSARIF)
+func (s ScanSummary) LogToSARIF(p string, config ScanConfig, recipe string) error {
+	f, err := os.Create(p)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	return s.WriteSARIF(f, config, recipe)
+}
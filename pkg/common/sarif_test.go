@@ -0,0 +1,118 @@
+package common
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+// -----------------------------------------------------------------------------
+// functions
+// -----------------------------------------------------------------------------
+
+// TestWriteSARIF compares SARIF log of clean scan and scan with findings with golden files
+func TestWriteSARIF(t *testing.T) {
+
+	tests := []struct {
+		golden  string
+		summary ScanSummary
+	}{
+		{golden: "sarif_clean.json", summary: cleanSummary()},
+		{golden: "sarif_findings.json", summary: findingsSummary()},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.golden, func(t *testing.T) {
+			var b bytes.Buffer
+			if err := tt.summary.WriteSARIF(&b, reportConfig(), ""); err != nil {
+				t.Fatal(err)
+			}
+			// uri of scanned folder depends on platform (volume of absolute path)
+			folder := strings.TrimSuffix(sarifFileURI(tt.summary.Folder), "/") + "/"
+			checkGolden(t, tt.golden, bytes.ReplaceAll(b.Bytes(), []byte(folder), []byte("file:///FOLDER/")))
+		})
+	}
+}
+
+// TestWriteSARIF_Schema checks properties of SARIF log required by schema and used by code scanning tools
+func TestWriteSARIF_Schema(t *testing.T) {
+
+	var b bytes.Buffer
+	if err := findingsSummary().WriteSARIF(&b, reportConfig(), ""); err != nil {
+		t.Fatal(err)
+	}
+
+	var log struct {
+		Version string `json:"version"`
+		Runs    []struct {
+			ColumnKind string `json:"columnKind"`
+			Tool       struct {
+				Driver struct {
+					Name  string `json:"name"`
+					Rules []struct {
+						ID string `json:"id"`
+					} `json:"rules"`
+				} `json:"driver"`
+			} `json:"tool"`
+			Results []struct {
+				RuleID    string `json:"ruleId"`
+				RuleIndex int    `json:"ruleIndex"`
+				Level     string `json:"level"`
+				Message   struct {
+					Text string `json:"text"`
+				} `json:"message"`
+				Locations []struct {
+					PhysicalLocation struct {
+						ArtifactLocation struct {
+							URI       string `json:"uri"`
+							URIBaseID string `json:"uriBaseId"`
+						} `json:"artifactLocation"`
+						Region struct {
+							StartLine   int `json:"startLine"`
+							StartColumn int `json:"startColumn"`
+							EndLine     int `json:"endLine"`
+							EndColumn   int `json:"endColumn"`
+						} `json:"region"`
+					} `json:"physicalLocation"`
+				} `json:"locations"`
+			} `json:"results"`
+		} `json:"runs"`
+	}
+	if err := json.Unmarshal(b.Bytes(), &log); err != nil {
+		t.Fatal(err)
+	}
+
+	if (log.Version != "2.1.0") || (len(log.Runs) != 1) {
+		t.Fatalf("version [%v] with [%v] runs, want version [2.1.0] with single run", log.Version, len(log.Runs))
+	}
+
+	run := log.Runs[0]
+	if run.ColumnKind != "unicodeCodePoints" {
+		t.Errorf("column kind [%v], want [unicodeCodePoints]", run.ColumnKind)
+	}
+	if run.Tool.Driver.Name != "gorex" {
+		t.Errorf("tool [%v], want [gorex]", run.Tool.Driver.Name)
+	}
+
+	if len(run.Results) != 3 {
+		t.Fatalf("[%v] results, want [3]", len(run.Results))
+	}
+	for _, r := range run.Results {
+		if (r.RuleIndex >= len(run.Tool.Driver.Rules)) || (run.Tool.Driver.Rules[r.RuleIndex].ID != r.RuleID) {
+			t.Errorf("rule index [%v] does not refer to rule [%v]", r.RuleIndex, r.RuleID)
+		}
+		if (r.Level == "") || (r.Message.Text == "") || (len(r.Locations) != 1) {
+			t.Errorf("result %+v without level, message or location", r)
+		}
+	}
+
+	// column of match after multibyte character is counted in characters
+	l := run.Results[0].Locations[0].PhysicalLocation
+	if (l.ArtifactLocation.URI != "a.txt") || (l.ArtifactLocation.URIBaseID != "SRCROOT") {
+		t.Errorf("artifact location %+v, want [a.txt] relative to [SRCROOT]", l.ArtifactLocation)
+	}
+	if (l.Region.StartLine != 2) || (l.Region.StartColumn != 3) || (l.Region.EndLine != 2) || (l.Region.EndColumn != 13) {
+		t.Errorf("region %+v, want [2:3..2:13]", l.Region)
+	}
+}
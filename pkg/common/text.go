@@ -0,0 +1,143 @@
+package common
+
+import (
+	"fmt"
+	"io"
+	"path/filepath"
+	"strings"
+)
+
+// -----------------------------------------------------------------------------
+// types
+// -----------------------------------------------------------------------------
+
+// TextOptions provides options of text report.
+// Color highlights matched text with ANSI escape codes (like grep --color).
+// BaseDir (when set) makes paths of files relative to it (e.g. to working directory of editor).
+// Null writes only names of files with found scopes, every name is followed by NUL character (like grep -lZ).
+type TextOptions struct {
+	Color   bool
+	BaseDir string
+	Null    bool
+}
+
+// ANSI escape codes of text report (colors used by grep)
+const (
+	textColorFile   = "\x1b[35m"
+	textColorNumber = "\x1b[32m"
+	textColorScope  = "\x1b[36m"
+	textColorMatch  = "\x1b[1;31m"
+	textColorReset  = "\x1b[0m"
+)
+
+// -----------------------------------------------------------------------------
+// functions
+// -----------------------------------------------------------------------------
+
+// textColored wraps text with color when colors are used
+func textColored(text string, color string, options TextOptions) string {
+	if (options.Color == false) || (text == "") {
+		return text
+	}
+	return color + text + textColorReset
+}
+
+// textFileName returns name of file relative to base directory of options (when it is set)
+func textFileName(fileName string, options TextOptions) string {
+	if options.BaseDir == "" {
+		return fileName
+	}
+	if rel, err := filepath.Rel(options.BaseDir, fileName); err == nil {
+		return rel
+	}
+	return fileName
+}
+
+// textColumn returns 1-based column of match counted in bytes of its first line (grep and vim count bytes,
+// Column of match is counted in characters)
+func textColumn(m MatchLine) int {
+	if m.Column <= 0 {
+		return 1
+	}
+
+	runes := []rune(strings.SplitN(m.Line, "\n", 2)[0])
+	column := m.Column - 1
+	if column > len(runes) {
+		column = len(runes)
+	}
+	return len(string(runes[:column])) + 1
+}
+
+// textMatch returns first line of match with highlighted matched characters (Column and EndColumn are counted in characters).
+// Match of multiline query is highlighted to the end of its first line.
+func textMatch(m MatchLine, options TextOptions) string {
+
+	line := strings.TrimRight(strings.SplitN(m.Line, "\n", 2)[0], "\r")
+	if (options.Color == false) || (m.Column <= 0) {
+		return line
+	}
+
+	runes := []rune(line)
+	start := m.Column - 1
+	end := m.EndColumn - 1
+	if (m.EndIndex > m.Index) || (end > len(runes)) {
+		end = len(runes)
+	}
+	if (start >= end) || (start >= len(runes)) {
+		return line
+	}
+
+	return string(runes[:start]) + textColored(string(runes[start:end]), textColorMatch, options) + string(runes[end:])
+}
+
+// -----------------------------------------------------------------------------
+// extensions
+// -----------------------------------------------------------------------------
+
+// WriteText writes summary as lines "file:line:column: scope: matched text" (format of grep, vim quickfix and Emacs compile).
+// Column is counted in bytes. Every match line is written (found scope without match lines is written with its start line).
+func (s ScanSummary) WriteText(w io.Writer, options TextOptions) error {
+
+	for _, f := range s.Summary {
+
+		fileName := textFileName(f.FileName, options)
+
+		if options.Null == true {
+			if len(f.Scopes) == 0 {
+				continue
+			}
+			if _, err := fmt.Fprintf(w, "%v\x00", fileName); err != nil {
+				return err
+			}
+			continue
+		}
+
+		prefix := textColored(fileName, textColorFile, options) + ":"
+
+		for _, scope := range f.Scopes {
+
+			name := textColored(scope.Name, textColorScope, options)
+
+			if len(scope.Matches) == 0 {
+				line := ""
+				if len(scope.Content) > 0 {
+					line = scope.Content[0]
+				}
+				if _, err := fmt.Fprintf(w, "%v%v:%v: %v: %v\n", prefix, textColored(fmt.Sprint(scope.Started), textColorNumber, options),
+					textColored("1", textColorNumber, options), name, line); err != nil {
+					return err
+				}
+				continue
+			}
+
+			for _, m := range scope.Matches {
+				if _, err := fmt.Fprintf(w, "%v%v:%v: %v: %v\n", prefix, textColored(fmt.Sprint(m.Index), textColorNumber, options),
+					textColored(fmt.Sprint(textColumn(m)), textColorNumber, options), name, textMatch(m, options)); err != nil {
+					return err
+				}
+			}
+		}
+	}
+
+	return nil
+}
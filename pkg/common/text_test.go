@@ -0,0 +1,37 @@
+package common
+
+import (
+	"bytes"
+	"testing"
+)
+
+// -----------------------------------------------------------------------------
+// functions
+// -----------------------------------------------------------------------------
+
+// TestWriteText_Column checks that column of match is written as byte offset of its line
+func TestWriteText_Column(t *testing.T) {
+
+	summary := ScanSummary{Summary: []FileScopeSummary{{
+		FileName: "a.txt",
+		Scopes: []ScopeSummary{{
+			Name: "s",
+			Matches: []MatchLine{
+				{Index: 1, Line: "zażółć x", Column: 8, EndColumn: 9},
+				{Index: 2, Line: "x", Column: 1, EndColumn: 2},
+				{Index: 3, Line: "żó\nx", Column: 3, EndIndex: 4, EndColumn: 2},
+				{Index: 4, Line: "ąb"},
+			},
+		}},
+	}}}
+
+	var b bytes.Buffer
+	if err := summary.WriteText(&b, TextOptions{}); err != nil {
+		t.Fatal(err)
+	}
+
+	want := "a.txt:1:12: s: zażółć x\na.txt:2:1: s: x\na.txt:3:5: s: żó\na.txt:4:1: s: ąb\n"
+	if b.String() != want {
+		t.Errorf("WriteText = %q, want %q", b.String(), want)
+	}
+}
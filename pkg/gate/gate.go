@@ -0,0 +1,214 @@
+// Package gate checks scan summary against fail conditions (e.g. forbidden scopes in CI pipeline).
+package gate
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	common "gorex/pkg/common"
+)
+
+// -----------------------------------------------------------------------------
+// types
+// -----------------------------------------------------------------------------
+
+// ConditionKind describes what is checked by condition
+type ConditionKind string
+
+const (
+	//ConditionAny fails when any scope is found ("any")
+	ConditionAny ConditionKind = "any"
+	//ConditionCount fails when number of found scopes is above limit ("count>N")
+	ConditionCount ConditionKind = "count"
+	//ConditionMatches fails when number of match lines is above limit ("matches>N")
+	ConditionMatches ConditionKind = "matches"
+	//ConditionScope fails when scope with given name is found ("scope=NAME")
+	ConditionScope ConditionKind = "scope"
+	//ConditionSeverity fails when scope with given (or higher) severity is found ("severity=LEVEL")
+	ConditionSeverity ConditionKind = "severity"
+)
+
+// Condition provides single fail condition. Limit is used by count and matches conditions,
+// Value by scope and severity conditions.
+type Condition struct {
+	Kind  ConditionKind
+	Limit int
+	Value string
+}
+
+// Failure provides condition which failed and number of scopes (or match lines) which broke it
+type Failure struct {
+	Condition Condition
+	Count     int
+}
+
+// Result provides totals of scan summary and failed conditions
+type Result struct {
+	ScanFiles  int
+	Files      int
+	Scopes     int
+	Matches    int
+	Incomplete bool
+	Failures   []Failure
+}
+
+// -----------------------------------------------------------------------------
+// functions
+// -----------------------------------------------------------------------------
+
+// ParseCondition reads condition written as "any", "count>N", "matches>N", "scope=NAME" or "severity=LEVEL"
+func ParseCondition(text string) (Condition, error) {
+
+	text = strings.TrimSpace(text)
+
+	if text == string(ConditionAny) {
+		return Condition{Kind: ConditionAny}, nil
+	}
+
+	if i := strings.Index(text, ">"); i > 0 {
+		kind := ConditionKind(strings.TrimSpace(text[:i]))
+		if (kind != ConditionCount) && (kind != ConditionMatches) {
+			return Condition{}, fmt.Errorf("Unknown fail condition [%v]", text)
+		}
+		limit, err := strconv.Atoi(strings.TrimSpace(text[i+1:]))
+		if (err != nil) || (limit < 0) {
+			return Condition{}, fmt.Errorf("Invalid limit of fail condition [%v]", text)
+		}
+		return Condition{Kind: kind, Limit: limit}, nil
+	}
+
+	if i := strings.Index(text, "="); i > 0 {
+		kind := ConditionKind(strings.TrimSpace(text[:i]))
+		value := strings.TrimSpace(text[i+1:])
+		if value == "" {
+			return Condition{}, fmt.Errorf("Empty value of fail condition [%v]", text)
+		}
+		switch kind {
+		case ConditionScope:
+		case ConditionSeverity:
+			if common.Severity(value).Rank() < 0 {
+				return Condition{}, fmt.Errorf("Unknown severity [%v] of fail condition [%v]", value, text)
+			}
+		default:
+			return Condition{}, fmt.Errorf("Unknown fail condition [%v]", text)
+		}
+		return Condition{Kind: kind, Value: value}, nil
+	}
+
+	return Condition{}, fmt.Errorf("Unknown fail condition [%v]", text)
+}
+
+// ParseConditions reads list of conditions (see ParseCondition)
+func ParseConditions(texts []string) ([]Condition, error) {
+	var result []Condition
+	for _, t := range texts {
+		c, err := ParseCondition(t)
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, c)
+	}
+	return result, nil
+}
+
+// Evaluate counts scopes and match lines of summary and checks every condition
+func Evaluate(summary common.ScanSummary, conditions []Condition) Result {
+
+	result := Result{
+		ScanFiles:  summary.ScanFiles,
+		Files:      summary.FilesWithScopes(),
+		Incomplete: summary.Incomplete,
+	}
+
+	for _, f := range summary.Summary {
+		result.Scopes += len(f.Scopes)
+		for _, s := range f.Scopes {
+			result.Matches += len(s.Matches)
+		}
+	}
+
+	for _, c := range conditions {
+		if count := c.count(summary, result); count > 0 {
+			result.Failures = append(result.Failures, Failure{Condition: c, Count: count})
+		}
+	}
+
+	return result
+}
+
+// -----------------------------------------------------------------------------
+// extensions
+// -----------------------------------------------------------------------------
+
+// String returns condition in the same form as it is parsed
+func (c Condition) String() string {
+	switch c.Kind {
+	case ConditionCount, ConditionMatches:
+		return fmt.Sprintf("%v>%v", c.Kind, c.Limit)
+	case ConditionScope, ConditionSeverity:
+		return fmt.Sprintf("%v=%v", c.Kind, c.Value)
+	default:
+		return string(c.Kind)
+	}
+}
+
+// count returns number of scopes (or match lines) which break condition (0 means condition is not broken)
+func (c Condition) count(summary common.ScanSummary, totals Result) int {
+
+	switch c.Kind {
+	case ConditionAny:
+		return totals.Scopes
+	case ConditionCount:
+		if totals.Scopes > c.Limit {
+			return totals.Scopes
+		}
+		return 0
+	case ConditionMatches:
+		if totals.Matches > c.Limit {
+			return totals.Matches
+		}
+		return 0
+	}
+
+	count := 0
+	for _, f := range summary.Summary {
+		for _, s := range f.Scopes {
+			if (c.Kind == ConditionScope) && (s.Name == c.Value) {
+				count++
+			}
+			if (c.Kind == ConditionSeverity) && (s.Severity != "") && (s.Severity.Rank() >= common.Severity(c.Value).Rank()) {
+				count++
+			}
+		}
+	}
+	return count
+}
+
+// Failed returns true when any condition failed
+func (r Result) Failed() bool {
+	return len(r.Failures) > 0
+}
+
+// String returns single line summary for CI logs, e.g.
+// "gorex: FAIL scanFiles=10 files=2 scopes=3 matches=7 incomplete=false failedOn=[scope=tx (2), count>1 (3)]"
+func (r Result) String() string {
+
+	status := "PASS"
+	if r.Failed() == true {
+		status = "FAIL"
+	}
+
+	line := fmt.Sprintf("gorex: %v scanFiles=%v files=%v scopes=%v matches=%v incomplete=%v",
+		status, r.ScanFiles, r.Files, r.Scopes, r.Matches, r.Incomplete)
+
+	if r.Failed() == true {
+		var failed []string
+		for _, f := range r.Failures {
+			failed = append(failed, fmt.Sprintf("%v (%v)", f.Condition, f.Count))
+		}
+		line += fmt.Sprintf(" failedOn=[%v]", strings.Join(failed, ", "))
+	}
+
+	return line
+}
@@ -0,0 +1,128 @@
+package gate
+
+import (
+	"reflect"
+	"testing"
+
+	common "gorex/pkg/common"
+)
+
+// -----------------------------------------------------------------------------
+// functions
+// -----------------------------------------------------------------------------
+
+// TestParseCondition checks every form of fail condition and invalid conditions
+func TestParseCondition(t *testing.T) {
+
+	tests := []struct {
+		text    string
+		want    Condition
+		wantErr bool
+	}{
+		{text: "any", want: Condition{Kind: ConditionAny}},
+		{text: "count>2", want: Condition{Kind: ConditionCount, Limit: 2}},
+		{text: "matches>0", want: Condition{Kind: ConditionMatches}},
+		{text: "scope=tx", want: Condition{Kind: ConditionScope, Value: "tx"}},
+		{text: "severity=warning", want: Condition{Kind: ConditionSeverity, Value: "warning"}},
+		{text: "", wantErr: true},
+		{text: "all", wantErr: true},
+		{text: "count>-1", wantErr: true},
+		{text: "count>x", wantErr: true},
+		{text: "lines>1", wantErr: true},
+		{text: "scope=", wantErr: true},
+		{text: "severity=fatal", wantErr: true},
+		{text: "name=tx", wantErr: true},
+		{text: ">1", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		got, err := ParseCondition(tt.text)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("ParseCondition(%q) error [%v], want error [%v]", tt.text, err, tt.wantErr)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("ParseCondition(%q) = %+v, want %+v", tt.text, got, tt.want)
+		}
+		// condition is written in the same form as it is parsed
+		if (tt.wantErr == false) && (got.String() != tt.text) {
+			t.Errorf("String() of %q = %q", tt.text, got.String())
+		}
+	}
+
+	if got, err := ParseCondition(" count > 2 "); (err != nil) || (got != Condition{Kind: ConditionCount, Limit: 2}) {
+		t.Errorf("ParseCondition with spaces = %+v (error [%v]), want count>2", got, err)
+	}
+}
+
+// TestEvaluate checks which conditions fail for summary and number of scopes (or match lines) which break them
+func TestEvaluate(t *testing.T) {
+
+	summary := common.ScanSummary{
+		ScanFiles: 4,
+		Summary: []common.FileScopeSummary{
+			{FileName: "a.txt", Scopes: []common.ScopeSummary{
+				{Name: "tx", Severity: common.SeverityError, Matches: []common.MatchLine{{Index: 1}, {Index: 2}}},
+				{Name: "cmd", Severity: common.SeverityInfo, Matches: []common.MatchLine{{Index: 3}}},
+			}},
+			{FileName: "b.txt", Scopes: []common.ScopeSummary{{Name: "tx"}}},
+			{FileName: "c.txt", TimedOutLines: []int{1}},
+		},
+	}
+
+	tests := []struct {
+		condition string
+		want      int
+	}{
+		{condition: "any", want: 3},
+		{condition: "count>2", want: 3},
+		{condition: "count>3", want: 0},
+		{condition: "matches>2", want: 3},
+		{condition: "matches>3", want: 0},
+		{condition: "scope=tx", want: 2},
+		{condition: "scope=other", want: 0},
+		{condition: "severity=info", want: 2},
+		{condition: "severity=warning", want: 1},
+		{condition: "severity=error", want: 1},
+	}
+
+	for _, tt := range tests {
+		c, err := ParseCondition(tt.condition)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		result := Evaluate(summary, []Condition{c})
+
+		if (result.ScanFiles != 4) || (result.Files != 2) || (result.Scopes != 3) || (result.Matches != 3) {
+			t.Errorf("totals %+v, want 4 scan files, 2 files, 3 scopes and 3 matches", result)
+		}
+
+		var want []Failure
+		if tt.want > 0 {
+			want = []Failure{{Condition: c, Count: tt.want}}
+		}
+		if reflect.DeepEqual(result.Failures, want) == false {
+			t.Errorf("%v failures %+v, want %+v", tt.condition, result.Failures, want)
+		}
+		if result.Failed() != (tt.want > 0) {
+			t.Errorf("%v failed [%v], want [%v]", tt.condition, result.Failed(), tt.want > 0)
+		}
+	}
+}
+
+// TestEvaluate_Clean checks that clean scan passes every condition
+func TestEvaluate_Clean(t *testing.T) {
+
+	conditions, err := ParseConditions([]string{"any", "count>0", "matches>0", "scope=tx", "severity=info"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	result := Evaluate(common.ScanSummary{ScanFiles: 2, Incomplete: true}, conditions)
+
+	want := "gorex: PASS scanFiles=2 files=0 scopes=0 matches=0 incomplete=true"
+	if result.String() != want {
+		t.Errorf("result %q, want %q", result.String(), want)
+	}
+}
@@ -0,0 +1,94 @@
+package scanner
+
+import (
+	"regexp"
+	"strconv"
+
+	common "gorex/pkg/common"
+
+	"github.com/dlclark/regexp2"
+)
+
+// placeholder is reference to named group of start query used in finish and search queries (e.g. ${name})
+var placeholder = regexp.MustCompile(`\$\{([A-Za-z_][A-Za-z0-9_]*)\}`)
+
+// -----------------------------------------------------------------------------
+// functions
+// -----------------------------------------------------------------------------
+
+// hasPlaceholders returns true when query refers to named groups of start query
+func hasPlaceholders(query string) bool {
+	return placeholder.MatchString(query)
+}
+
+// expandQuery replaces every ${name} placeholder in query with value captured by start query
+// (escaped for engine of query). Placeholder of not captured group is replaced with empty string.
+func expandQuery(query string, captures map[string]string, engine common.QueryEngine) string {
+	if hasPlaceholders(query) == false {
+		return query
+	}
+
+	return placeholder.ReplaceAllStringFunc(query, func(p string) string {
+		name := placeholder.FindStringSubmatch(p)[1]
+		return escapeForEngine(engine, captures[name])
+	})
+}
+
+// findCaptures returns values of named groups of rx found in line
+func findCaptures(rx matcher, line string) (map[string]string, error) {
+	if rx == nil {
+		return nil, nil
+	}
+
+	m, err := rx.find(line)
+	if m == nil {
+		return nil, err
+	}
+
+	return m.groups, nil
+}
+
+// groupNames returns names of named groups of matcher (engines of literals have no groups)
+func groupNames(m matcher) []string {
+
+	var result []string
+	switch rx := m.(type) {
+	case *regexp2Matcher:
+		for _, name := range rx.rx.GetGroupNames() {
+			if _, err := strconv.Atoi(name); err != nil {
+				result = append(result, name)
+			}
+		}
+	case *regexpMatcher:
+		for _, name := range rx.rx.SubexpNames() {
+			if name != "" {
+				result = append(result, name)
+			}
+		}
+	}
+	return result
+}
+
+// namedGroups returns values of named groups of match (nil when rx has no named groups)
+func namedGroups(rx *regexp2.Regexp, m *regexp2.Match) map[string]string {
+
+	var result map[string]string
+	for _, name := range rx.GetGroupNames() {
+		if _, err := strconv.Atoi(name); err == nil {
+			// numbered group
+			continue
+		}
+
+		g := m.GroupByName(name)
+		if (g == nil) || (len(g.Captures) == 0) {
+			continue
+		}
+
+		if result == nil {
+			result = make(map[string]string)
+		}
+		result[name] = g.String()
+	}
+
+	return result
+}
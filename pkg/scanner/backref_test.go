@@ -0,0 +1,97 @@
+package scanner
+
+import (
+	"testing"
+
+	common "gorex/pkg/common"
+)
+
+// -----------------------------------------------------------------------------
+// functions
+// -----------------------------------------------------------------------------
+
+// TestExpandQuery checks that placeholders are replaced with captured values escaped for engine
+func TestExpandQuery(t *testing.T) {
+
+	captures := map[string]string{"v": `1.5*(x)|$y`}
+
+	tests := []struct {
+		query  string
+		engine common.QueryEngine
+		want   string
+	}{
+		{query: `^v=${v}$`, engine: common.QueryEngineRegexp2, want: `^v=1\.5\*\(x\)\|\$y$`},
+		{query: `^v=${v}$`, engine: common.QueryEngineRegexp, want: `^v=1\.5\*\(x\)\|\$y$`},
+		{query: `v=${v}`, engine: common.QueryEngineLiteral, want: `v=1.5*(x)|$y`},
+		{query: `v=${v}`, engine: common.QueryEngineAhoCorasick, want: `v=1.5*(x)|$y`},
+		{query: `v=${other}.`, engine: common.QueryEngineRegexp2, want: `v=.`},
+		{query: `v=$v`, engine: common.QueryEngineRegexp2, want: `v=$v`},
+	}
+
+	for _, tt := range tests {
+		if got := expandQuery(tt.query, captures, tt.engine); got != tt.want {
+			t.Errorf("expandQuery(%q) of [%v] = %q, want %q", tt.query, tt.engine.OrDefault(), got, tt.want)
+		}
+	}
+}
+
+// TestCompileExpanded_Escape checks that captured value with metacharacters is matched literally by every engine
+func TestCompileExpanded_Escape(t *testing.T) {
+
+	captures := map[string]string{"v": `1.5*(x)|$y`}
+	engines := []common.QueryEngine{common.QueryEngineRegexp2, common.QueryEngineRegexp, common.QueryEngineLiteral, common.QueryEngineAhoCorasick}
+
+	for _, engine := range engines {
+
+		m, err := compileExpanded(`v=${v}`, captures, engine, "", false, 0)
+		if err != nil {
+			t.Fatalf("[%v]: %v", engine, err)
+		}
+
+		for text, want := range map[string]bool{"v=1.5*(x)|$y": true, "v=1x5*(x)|$y": false, "v=15(x)": false, "$y": false} {
+			if got, _ := m.matchString(text); got != want {
+				t.Errorf("[%v] matchString(%q) = %v, want %v", engine, text, got, want)
+			}
+		}
+	}
+}
+
+// TestScopeMachine_FinishQueryCapture checks that finish query refers to value captured by start line of scope
+func TestScopeMachine_FinishQueryCapture(t *testing.T) {
+
+	content := "BEGIN a.b\nx\nEND axb\nEND a.b\nBEGIN c\nx\nEND a.b\nEND c\n"
+
+	runScopeTests(t, []scopeTest{
+		{
+			name: "regexp2 engine",
+			scopes: []common.ScopeConfig{{
+				Name: "s", StartQuery: `^BEGIN (?<tag>\S+)$`, FinishQuery: `^END ${tag}$`, SearchQuery: []common.Query{{Pattern: `^x$`}},
+			}},
+			content: content,
+			want: []foundScope{
+				{Name: "s", Started: 1, Finished: 4, Content: []string{"BEGIN a.b", "x", "END axb", "END a.b"}, Matches: []int{2}},
+				{Name: "s", Started: 5, Finished: 8, Content: []string{"BEGIN c", "x", "END a.b", "END c"}, Matches: []int{6}},
+			},
+		},
+		{
+			name: "regexp engine",
+			scopes: []common.ScopeConfig{{
+				Name: "s", StartQuery: `^BEGIN (?P<tag>\S+)$`, StartQueryEngine: common.QueryEngineRegexp,
+				FinishQuery: `^END ${tag}$`, FinishQueryEngine: common.QueryEngineRegexp, SearchQuery: []common.Query{{Pattern: `^x$`}},
+			}},
+			content: content,
+			want: []foundScope{
+				{Name: "s", Started: 1, Finished: 4, Content: []string{"BEGIN a.b", "x", "END axb", "END a.b"}, Matches: []int{2}},
+				{Name: "s", Started: 5, Finished: 8, Content: []string{"BEGIN c", "x", "END a.b", "END c"}, Matches: []int{6}},
+			},
+		},
+		{
+			name: "search query",
+			scopes: []common.ScopeConfig{{
+				Name: "s", StartQuery: `^BEGIN (?<tag>\S+)$`, FinishQuery: `^END`, SearchQuery: []common.Query{{Pattern: `^use ${tag}$`}},
+			}},
+			content: "BEGIN a.b\nuse axb\nEND\nBEGIN a.b\nuse a.b\nEND\n",
+			want:    []foundScope{{Name: "s", Started: 4, Finished: 6, Content: []string{"BEGIN a.b", "use a.b", "END"}, Matches: []int{5}}},
+		},
+	})
+}
@@ -0,0 +1,200 @@
+package scanner
+
+import (
+	"strings"
+	"unicode/utf8"
+
+	common "gorex/pkg/common"
+)
+
+// -----------------------------------------------------------------------------
+// types
+// -----------------------------------------------------------------------------
+
+// scopeCloser decides which line finishes open scope. New closer is created for every open scope.
+type scopeCloser interface {
+	// begin processes start line of scope and returns true when scope is finished in the same line
+	begin(line string) bool
+	// next processes line of open scope and returns true when line finishes scope.
+	// When included is false then line does not belong to scope (scope is finished in previous line).
+	next(line string) (finished bool, included bool)
+}
+
+// regexCloser finishes scope on line which matches finish query (ScopeModeRegex)
+type regexCloser struct {
+	rx       matcher
+	timeouts *fileTimeouts
+}
+
+// balancedCloser finishes scope when delimiters are balanced again (ScopeModeBalanced)
+type balancedCloser struct {
+	cfg            common.BalancedConfig
+	depth          int
+	opened         bool
+	inBlockComment bool
+}
+
+// trailingCloser is closer which finishes scope before its last lines (they are passed to closer
+// before it knows that scope is finished)
+type trailingCloser interface {
+	// trailing returns number of last lines of open scope which do not belong to scope when it is finished
+	trailing() int
+}
+
+// indentCloser finishes scope before first non blank line which is not indented deeper
+// than start line (ScopeModeIndent). Blank lines before that line do not belong to scope.
+type indentCloser struct {
+	tabWidth int
+	indent   int
+	blank    int
+}
+
+// fileCloser never finishes scope, so scope is finished at the end of file (ScopeModeFile)
+type fileCloser struct {
+}
+
+// windowCloser finishes scope after number of lines following start line (ScopeModeWindow)
+type windowCloser struct {
+	left int
+}
+
+// blankCloser finishes scope before first blank line (ScopeModeBlank)
+type blankCloser struct {
+}
+
+// -----------------------------------------------------------------------------
+// extensions
+// -----------------------------------------------------------------------------
+
+func (c *regexCloser) begin(line string) bool {
+	return false
+}
+
+func (c *regexCloser) next(line string) (bool, bool) {
+	finished, err := checkIfEndScope(line, c.rx, true)
+	c.timeouts.add(c.timeouts.line, err)
+	return finished, true
+}
+
+func (c *balancedCloser) begin(line string) bool {
+	return c.count(line)
+}
+
+func (c *balancedCloser) next(line string) (bool, bool) {
+	return c.count(line), true
+}
+
+// count counts delimiters in line. Scope is finished when at least one delimiter was opened
+// and depth is zero again. Close delimiter found before first open delimiter is ignored.
+func (c *balancedCloser) count(line string) bool {
+
+	var quote rune
+
+	for i := 0; i < len(line); {
+		rest := line[i:]
+		r, size := utf8.DecodeRuneInString(rest)
+
+		switch {
+		case c.inBlockComment:
+			if strings.HasPrefix(rest, c.cfg.BlockCommentEnd) {
+				c.inBlockComment = false
+				size = len(c.cfg.BlockCommentEnd)
+			}
+		case quote != 0:
+			if r == '\\' {
+				// skip escaped character
+				_, escaped := utf8.DecodeRuneInString(line[i+size:])
+				size += escaped
+			} else if r == quote {
+				quote = 0
+			}
+		case (c.cfg.LineComment != "") && strings.HasPrefix(rest, c.cfg.LineComment):
+			return false
+		case (c.cfg.BlockCommentStart != "") && strings.HasPrefix(rest, c.cfg.BlockCommentStart):
+			c.inBlockComment = true
+			size = len(c.cfg.BlockCommentStart)
+		case strings.ContainsRune(c.cfg.Quotes, r):
+			quote = r
+		case strings.HasPrefix(rest, c.cfg.Open):
+			c.depth++
+			c.opened = true
+			size = len(c.cfg.Open)
+		case strings.HasPrefix(rest, c.cfg.Close):
+			size = len(c.cfg.Close)
+			if c.depth > 0 {
+				c.depth--
+				if c.depth == 0 {
+					return true
+				}
+			}
+		}
+
+		i += size
+	}
+
+	return false
+}
+
+func (c *indentCloser) begin(line string) bool {
+	c.indent = indentation(line, c.tabWidth)
+	return false
+}
+
+func (c *indentCloser) next(line string) (bool, bool) {
+	if strings.TrimSpace(line) == "" {
+		c.blank++
+		return false, true
+	}
+
+	if indentation(line, c.tabWidth) <= c.indent {
+		return true, false
+	}
+
+	c.blank = 0
+	return false, true
+}
+
+func (c *indentCloser) trailing() int {
+	return c.blank
+}
+
+// indentation returns width of leading white spaces of line. Tab moves to next multiple of tabWidth.
+func indentation(line string, tabWidth int) int {
+	result := 0
+	for _, r := range line {
+		switch r {
+		case ' ':
+			result++
+		case '\t':
+			result += tabWidth - (result % tabWidth)
+		default:
+			return result
+		}
+	}
+	return result
+}
+
+func (c *fileCloser) begin(line string) bool {
+	return false
+}
+
+func (c *fileCloser) next(line string) (bool, bool) {
+	return false, true
+}
+
+func (c *windowCloser) begin(line string) bool {
+	return c.left <= 0
+}
+
+func (c *windowCloser) next(line string) (bool, bool) {
+	c.left--
+	return c.left <= 0, true
+}
+
+func (c *blankCloser) begin(line string) bool {
+	return false
+}
+
+func (c *blankCloser) next(line string) (bool, bool) {
+	return strings.TrimSpace(line) == "", false
+}
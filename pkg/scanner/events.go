@@ -0,0 +1,114 @@
+package scanner
+
+import (
+	"sync"
+
+	common "gorex/pkg/common"
+)
+
+// -----------------------------------------------------------------------------
+// types
+// -----------------------------------------------------------------------------
+
+// Events provides callbacks executed by Scanner while files are processed.
+// Every callback is optional (nil callback is skipped).
+// Callbacks are never executed concurrently, but when many workers are used
+// callbacks of different files can be interleaved.
+type Events struct {
+	// OnFileStart is executed before first line of file is read
+	OnFileStart func(fileName string)
+	// OnScopeOpened is executed when start of scope is found
+	OnScopeOpened func(scope common.ScopeSummary)
+	// OnScopeClosed is executed for every closed scope (with or without matches)
+	OnScopeClosed func(scope common.ScopeSummary)
+	// OnMatch is executed for every match line of closed scope which fulfils search queries
+	OnMatch func(scope common.ScopeSummary, match common.MatchLine)
+	// OnFileDone is executed when file is processed
+	OnFileDone func(summary common.FileScopeSummary)
+}
+
+// -----------------------------------------------------------------------------
+// extensions
+// -----------------------------------------------------------------------------
+
+// synchronized returns events which callbacks are guarded by common mutex
+func (e *Events) synchronized() *Events {
+	if e == nil {
+		return nil
+	}
+
+	mutex := &sync.Mutex{}
+	result := &Events{}
+
+	if e.OnFileStart != nil {
+		result.OnFileStart = func(fileName string) {
+			mutex.Lock()
+			defer mutex.Unlock()
+			e.OnFileStart(fileName)
+		}
+	}
+	if e.OnScopeOpened != nil {
+		result.OnScopeOpened = func(scope common.ScopeSummary) {
+			mutex.Lock()
+			defer mutex.Unlock()
+			e.OnScopeOpened(scope)
+		}
+	}
+	if e.OnScopeClosed != nil {
+		result.OnScopeClosed = func(scope common.ScopeSummary) {
+			mutex.Lock()
+			defer mutex.Unlock()
+			e.OnScopeClosed(scope)
+		}
+	}
+	if e.OnMatch != nil {
+		result.OnMatch = func(scope common.ScopeSummary, match common.MatchLine) {
+			mutex.Lock()
+			defer mutex.Unlock()
+			e.OnMatch(scope, match)
+		}
+	}
+	if e.OnFileDone != nil {
+		result.OnFileDone = func(summary common.FileScopeSummary) {
+			mutex.Lock()
+			defer mutex.Unlock()
+			e.OnFileDone(summary)
+		}
+	}
+
+	return result
+}
+
+func (e *Events) fileStart(fileName string) {
+	if (e != nil) && (e.OnFileStart != nil) {
+		e.OnFileStart(fileName)
+	}
+}
+
+func (e *Events) scopeOpened(scope common.ScopeSummary) {
+	if (e != nil) && (e.OnScopeOpened != nil) {
+		e.OnScopeOpened(scope)
+	}
+}
+
+func (e *Events) scopeClosed(scope common.ScopeSummary) {
+	if e == nil {
+		return
+	}
+
+	if e.OnMatch != nil {
+		for _, m := range scope.Matches {
+			e.OnMatch(scope, m)
+		}
+	}
+
+	if e.OnScopeClosed != nil {
+		e.OnScopeClosed(scope)
+	}
+}
+
+func (e *Events) fileDone(summary common.FileScopeSummary) {
+	if (e != nil) && (e.OnFileDone != nil) {
+		e.OnFileDone(summary)
+	}
+}
@@ -0,0 +1,139 @@
+package scanner
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	common "gorex/pkg/common"
+
+	"github.com/rs/zerolog"
+)
+
+// -----------------------------------------------------------------------------
+// functions
+// -----------------------------------------------------------------------------
+
+// newStreamScanner returns scanner (single worker, so files are processed in order of names) of files
+// written into temporary folder
+func newStreamScanner(t *testing.T, files map[string]string) *Scanner {
+	t.Helper()
+
+	folder, err := ioutil.TempDir("", "gorex")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { os.RemoveAll(folder) })
+
+	writeFiles(t, folder, files)
+
+	s, err := New(common.ScanConfig{
+		Folder:  folder,
+		Filter:  "*.txt",
+		Workers: 1,
+		Scopes: []common.ScopeConfig{{
+			Name: "s", StartQuery: `^BEGIN$`, FinishQuery: `^END$`, SearchQuery: []common.Query{{Pattern: `x`}},
+		}},
+	}, zerolog.Nop())
+	if err != nil {
+		t.Fatal(err)
+	}
+	return s
+}
+
+// recordEvents returns events which describe every callback in log (cancel is executed when first scope is closed)
+func recordEvents(log *[]string, cancel func()) Events {
+	return Events{
+		OnFileStart: func(fileName string) {
+			*log = append(*log, "start "+filepath.Base(fileName))
+		},
+		OnScopeOpened: func(scope common.ScopeSummary) {
+			*log = append(*log, fmt.Sprintf("open %v[%v]", scope.Name, scope.Started))
+		},
+		OnMatch: func(scope common.ScopeSummary, match common.MatchLine) {
+			*log = append(*log, fmt.Sprintf("match %v[%v] %v", scope.Name, scope.Started, match.Index))
+		},
+		OnScopeClosed: func(scope common.ScopeSummary) {
+			*log = append(*log, fmt.Sprintf("close %v[%v..%v]", scope.Name, scope.Started, scope.Finished))
+			if cancel != nil {
+				cancel()
+			}
+		},
+		OnFileDone: func(summary common.FileScopeSummary) {
+			*log = append(*log, fmt.Sprintf("done %v matches=%v aborted=%v", filepath.Base(summary.FileName), summary.AllMatches, summary.Aborted))
+		},
+	}
+}
+
+// TestStream_Order checks order of events of every file and that no event is executed after stream returns
+func TestStream_Order(t *testing.T) {
+
+	s := newStreamScanner(t, map[string]string{
+		"a.txt": "BEGIN\nx\nEND\nBEGIN\ny\nEND\n",
+		"b.txt": "x\n",
+	})
+
+	var log []string
+	summary, err := s.Stream(recordEvents(&log, nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := []string{
+		"start a.txt",
+		"open s[1]",
+		"match s[1] 2",
+		"close s[1..3]",
+		"open s[4]",
+		"close s[4..6]",
+		"done a.txt matches=1 aborted=false",
+		"start b.txt",
+		"done b.txt matches=0 aborted=false",
+	}
+	if reflect.DeepEqual(log, want) == false {
+		t.Errorf("events %q, want %q", log, want)
+	}
+
+	// scopes are not collected by stream
+	if (summary.ScanFiles != 2) || (summary.Summary != nil) || (summary.FilesWithoutScopes != nil) || (summary.Incomplete == true) {
+		t.Errorf("summary %+v, want counters of [2] files only", summary)
+	}
+}
+
+// TestStreamContext_Cancel checks that stream cancelled in the middle of first file finishes the file
+// (flagged as aborted) and returns without events of remaining files
+func TestStreamContext_Cancel(t *testing.T) {
+
+	s := newStreamScanner(t, map[string]string{
+		"a.txt": "BEGIN\nx\nEND\nBEGIN\nx\nEND\n",
+		"b.txt": "BEGIN\nx\nEND\n",
+	})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	var log []string
+	summary, err := s.StreamContext(ctx, recordEvents(&log, cancel))
+	if err != context.Canceled {
+		t.Errorf("error [%v], want [%v]", err, context.Canceled)
+	}
+
+	want := []string{
+		"start a.txt",
+		"open s[1]",
+		"match s[1] 2",
+		"close s[1..3]",
+		"done a.txt matches=1 aborted=true",
+	}
+	if reflect.DeepEqual(log, want) == false {
+		t.Errorf("events %q, want %q", log, want)
+	}
+
+	if (summary.Incomplete == false) || (summary.ScanFiles != 1) {
+		t.Errorf("summary %+v, want incomplete summary of [1] file", summary)
+	}
+}
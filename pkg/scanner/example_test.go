@@ -0,0 +1,76 @@
+package scanner_test
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	common "gorex/pkg/common"
+	scanner "gorex/pkg/scanner"
+
+	"github.com/rs/zerolog"
+)
+
+// -----------------------------------------------------------------------------
+// functions
+// -----------------------------------------------------------------------------
+
+// Example_scan scans folder for transactions which contain command and prints summary
+func Example_scan() {
+
+	folder, err := ioutil.TempDir("", "gorex")
+	if err != nil {
+		fmt.Println(err)
+		return
+	}
+	defer os.RemoveAll(folder)
+
+	files := map[string]string{
+		"a.txt": "BEGIN\nCOMMAND=ls\nEND\nBEGIN\nEND\n",
+		"b.txt": "nothing\n",
+	}
+	for name, content := range files {
+		if err := ioutil.WriteFile(filepath.Join(folder, name), []byte(content), 0644); err != nil {
+			fmt.Println(err)
+			return
+		}
+	}
+
+	s, err := scanner.New(common.ScanConfig{
+		Folder: folder,
+		Filter: "*.txt",
+		Scopes: []common.ScopeConfig{{
+			Name:        "transaction",
+			StartQuery:  `^BEGIN$`,
+			FinishQuery: `^END$`,
+			SearchQuery: []common.Query{{Pattern: `COMMAND=(?<command>\w+)`}},
+		}},
+	}, zerolog.Nop())
+	if err != nil {
+		fmt.Println(err)
+		return
+	}
+
+	summary, err := s.Scan()
+	if err != nil {
+		fmt.Println(err)
+		return
+	}
+
+	// names of files are paths in scanned folder
+	fmt.Printf("scanned %v file(s), found in %v file(s), without scopes %v\n", summary.ScanFiles, summary.FilesWithScopes(), len(summary.FilesWithoutScopes))
+	for _, f := range summary.Summary {
+		for _, scope := range f.Scopes {
+			fmt.Printf("%v [%v..%v] %v\n", filepath.Base(f.FileName), scope.Started, scope.Finished, scope.Name)
+			for _, m := range scope.Matches {
+				fmt.Printf("  [%v] %v command=%v\n", m.Index, m.Line, m.Captures["command"])
+			}
+		}
+	}
+
+	// Output:
+	// scanned 2 file(s), found in 1 file(s), without scopes 1
+	// a.txt [1..3] transaction
+	//   [2] COMMAND=ls command=ls
+}
@@ -0,0 +1,191 @@
+package scanner
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	common "gorex/pkg/common"
+
+	"github.com/rs/zerolog"
+)
+
+// -----------------------------------------------------------------------------
+// types
+// -----------------------------------------------------------------------------
+
+// expressionResult provides result of evaluation of single node of QueryExpression
+// Lines map offsets of content lines to label of query which matches the line.
+type expressionResult struct {
+	matched     bool
+	lines       map[int]string
+	explanation string
+}
+
+// -----------------------------------------------------------------------------
+// functions
+// -----------------------------------------------------------------------------
+
+// findExpressionInScope evaluates search expression of scope. Queries are compiled in order of SearchQuery.
+// Match lines are lines of queries which make expression true. Scope is accepted when expression is true,
+// even without match lines (e.g. expression "not" which is true when query is not found).
+func findExpressionInScope(scope common.ScopeSummaryWithConfig, rx []matcher, exclude []matcher, timeouts *fileTimeouts, logger zerolog.Logger) (common.ScopeSummary, bool, error) {
+
+	var result common.ScopeSummary = scope.ScopeSummary
+
+	// offsets of lines matched by every named query and matches of first query of every line
+	hits := make(map[string][]int)
+	matches := make(map[int]map[string][]common.MatchLine)
+	for j, q := range scope.ScopeConfig.SearchQuery {
+		if q.Name == "" {
+			continue
+		}
+		queryMatches := findQueryMatches(scope.ScopeSummary.Content, scope.ScopeSummary.Started, rx[j], q, timeouts)
+		for _, i := range sortedOffsets(queryMatches) {
+			hits[q.Name] = append(hits[q.Name], i)
+			if matches[i] == nil {
+				matches[i] = make(map[string][]common.MatchLine)
+			}
+			matches[i][q.Name] = queryMatches[i]
+		}
+	}
+
+	queries := make(map[string]common.Query)
+	for _, q := range scope.ScopeConfig.SearchQuery {
+		queries[q.Name] = q
+	}
+
+	r := evaluateExpression(*scope.ScopeConfig.SearchExpression, queries, hits)
+	logger.Trace().Msgf("\t\tSEARCH EXPRESSION IN SCOPE [%06d..%06d]: %v", result.Started, result.Finished, r.explanation)
+
+	if r.matched == false {
+		return result, false, nil
+	}
+
+	if (len(r.lines) > 0) && (scope.ScopeConfig.AcceptsMatches(len(r.lines)) == false) {
+		logger.Trace().Msgf("\t\tSCOPE [%06d..%06d] REJECTED, [%v] lines out of limits [%v..%v]", result.Started, result.Finished, len(r.lines), scope.ScopeConfig.MinMatches, scope.ScopeConfig.MaxMatches)
+		return result, false, nil
+	}
+
+	excluded := findExcludedInScope(scope, exclude, timeouts)
+	if excluded != "" {
+		logger.Trace().Msgf("\t\tSCOPE [%06d..%06d] REJECTED, excluded query found in line: %v", result.Started, result.Finished, excluded)
+		return result, false, nil
+	}
+
+	var offsets []int
+	for i := range r.lines {
+		offsets = append(offsets, i)
+	}
+	sort.Ints(offsets)
+
+	var matchLines []common.MatchLine
+	for _, i := range offsets {
+		for _, match := range matches[i][r.lines[i]] {
+			markMatch(&logger, scope, match)
+			matchLines = append(matchLines, match)
+		}
+	}
+
+	counts := make([]int, len(scope.ScopeConfig.SearchQuery))
+	for j, q := range scope.ScopeConfig.SearchQuery {
+		counts[j] = len(hits[q.Name])
+	}
+
+	result.Matches = matchLines
+	result.Counts = queryCounts(scope.ScopeConfig.SearchQuery, counts)
+	result.Reason = "search expression matched: " + r.explanation + constraintsReason(scope.ScopeConfig)
+
+	return result, true, nil
+}
+
+// evaluateExpression evaluates node of expression. Hits contains offsets of lines matched by every named query.
+// Query is true when number of its lines fulfils limits of the query.
+func evaluateExpression(e common.QueryExpression, queries map[string]common.Query, hits map[string][]int) expressionResult {
+
+	op := e.Operator()
+	result := expressionResult{
+		lines: make(map[int]string),
+	}
+
+	if op == common.ExpressionOperatorQuery {
+		for _, i := range hits[e.Query] {
+			result.lines[i] = e.Query
+		}
+		q := queries[e.Query]
+		result.matched = q.Accepts(len(result.lines))
+		result.explanation = fmt.Sprintf("%v=%v", e.Query, len(result.lines))
+		if (q.Min > 0) || (q.Max > 0) {
+			result.explanation += fmt.Sprintf("[%v..%v]", q.Min, q.Max)
+		}
+		if result.matched == false {
+			result.lines = make(map[int]string)
+		}
+		return result
+	}
+
+	var explanations []string
+
+	switch op {
+	case common.ExpressionOperatorAnd:
+		result.matched = true
+		for _, n := range e.Nodes {
+			r := evaluateExpression(n, queries, hits)
+			result.matched = result.matched && r.matched
+			result.addLines(r)
+			explanations = append(explanations, r.explanation)
+		}
+	case common.ExpressionOperatorOr:
+		for _, n := range e.Nodes {
+			r := evaluateExpression(n, queries, hits)
+			result.matched = result.matched || r.matched
+			if r.matched == true {
+				result.addLines(r)
+			}
+			explanations = append(explanations, r.explanation)
+		}
+	case common.ExpressionOperatorNot:
+		r := evaluateExpression(e.Nodes[0], queries, hits)
+		result.matched = !r.matched
+		explanations = append(explanations, r.explanation)
+	case common.ExpressionOperatorSequence:
+		// every query should be found after line of previous query
+		result.matched = true
+		previous := -1
+		for _, n := range e.Nodes {
+			found := -1
+			count := 0
+			for _, i := range hits[n.Query] {
+				if i > previous {
+					if found < 0 {
+						found = i
+					}
+					result.lines[i] = n.Query
+					count++
+				}
+			}
+			explanations = append(explanations, fmt.Sprintf("%v=%v", n.Query, count))
+			if queries[n.Query].Accepts(count) == false {
+				result.matched = false
+				break
+			}
+			previous = found
+		}
+	}
+
+	if result.matched == false {
+		result.lines = make(map[int]string)
+	}
+
+	result.explanation = fmt.Sprintf("%v=%v(%v)", op, result.matched, strings.Join(explanations, ", "))
+
+	return result
+}
+
+func (r *expressionResult) addLines(n expressionResult) {
+	for i, q := range n.lines {
+		if _, ok := r.lines[i]; ok == false {
+			r.lines[i] = q
+		}
+	}
+}
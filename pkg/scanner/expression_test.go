@@ -0,0 +1,40 @@
+package scanner
+
+import (
+	"testing"
+
+	common "gorex/pkg/common"
+)
+
+// -----------------------------------------------------------------------------
+// functions
+// -----------------------------------------------------------------------------
+
+// TestFindExpressionInScope_Not checks that scope is reported when top level "not" expression is true
+func TestFindExpressionInScope_Not(t *testing.T) {
+
+	config := common.ScanConfig{Scopes: []common.ScopeConfig{{
+		Name:             "s",
+		StartQuery:       `^BEGIN$`,
+		FinishQuery:      `^END$`,
+		SearchQuery:      []common.Query{{Name: "C", Pattern: `COMMIT`}},
+		SearchExpression: &common.QueryExpression{Op: common.ExpressionOperatorNot, Nodes: []common.QueryExpression{{Query: "C"}}},
+	}}}
+
+	summary := scanFiles(t, config, map[string]string{"a.txt": "BEGIN\nx\nEND\nBEGIN\nCOMMIT\nEND\n"})
+
+	if len(summary.Summary) != 1 || len(summary.Summary[0].Scopes) != 1 {
+		t.Fatalf("found %+v, want one scope", summary.Summary)
+	}
+
+	scope := summary.Summary[0].Scopes[0]
+	if (scope.Started != 1) || (scope.Finished != 3) || (len(scope.Matches) != 0) {
+		t.Errorf("found scope [%v..%v] with [%v] matches, want scope [1..3] without matches", scope.Started, scope.Finished, len(scope.Matches))
+	}
+	if want := "search expression matched: not=true(C=0)"; scope.Reason != want {
+		t.Errorf("reason [%v], want [%v]", scope.Reason, want)
+	}
+	if summary.Summary[0].AllMatches != 1 {
+		t.Errorf("all matches [%v], want [1]", summary.Summary[0].AllMatches)
+	}
+}
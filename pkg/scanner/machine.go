@@ -0,0 +1,466 @@
+package scanner
+
+import (
+	"fmt"
+	"html"
+	"strings"
+	"time"
+
+	common "gorex/pkg/common"
+
+	"github.com/rs/zerolog"
+)
+
+// -----------------------------------------------------------------------------
+// types
+// -----------------------------------------------------------------------------
+
+// compiledScope provides scope configuration with compiled queries.
+// Query which refers to named groups of start query is compiled when scope is open (its matcher is nil).
+// Finish query is not used (and compiled) by scopes which are not in ScopeModeRegex.
+// Timeout is match timeout of scan used by queries (also start and finish query) which do not define own timeout.
+// Groups contains names of named groups of start and search queries (captured values of found scopes).
+type compiledScope struct {
+	config   common.ScopeConfig
+	timeout  time.Duration
+	rxStart  matcher
+	rxStop   matcher
+	search   []matcher
+	exclude  []matcher
+	groups   []string
+	children []*compiledScope
+}
+
+// scopeMachine tracks state of single scope configuration while file is read line by line.
+// Every line of file is passed to every top level machine, so file is read only once.
+// Machines of child scopes receive only lines inside currently open scope of parent machine (see passesEdgeLines).
+type scopeMachine struct {
+	scope        *compiledScope
+	logger       *zerolog.Logger
+	events       *Events
+	collect      bool
+	fileName     string
+	depth        int
+	parents      []common.ScopeParent
+	children     []*scopeMachine
+	scopeIsOpen  bool
+	closer       scopeCloser
+	scopeSummary common.ScopeSummary
+	result       common.FileScopeSummary
+	timeouts     *fileTimeouts
+}
+
+// -----------------------------------------------------------------------------
+// functions
+// -----------------------------------------------------------------------------
+
+// compileScope compiles every query of scope and its child scopes. Path is index of scope (e.g. "0.1") used in errors.
+// Queries with placeholders are compiled with empty values to check their syntax.
+func compileScope(config common.ScopeConfig, path string, timeout time.Duration) (*compiledScope, error) {
+	result := &compiledScope{
+		config:  config,
+		timeout: timeout,
+	}
+
+	var err error
+
+	if config.ScopeMode() != common.ScopeModeFile {
+		result.rxStart, err = compileMatcher(config.StartQuery, config.StartQueryEngine, config.StartQueryOptions, false, queryTimeout(config.StartQueryTimeout, timeout))
+		if err != nil {
+			return nil, fmt.Errorf("Invalid start query of scope [%v]: %v", path, err)
+		}
+		result.groups = append(result.groups, groupNames(result.rxStart)...)
+	}
+
+	if config.ScopeMode() == common.ScopeModeRegex {
+		rx, err := compileExpanded(config.FinishQuery, nil, config.FinishQueryEngine, config.FinishQueryOptions, false, queryTimeout(config.FinishQueryTimeout, timeout))
+		if err != nil {
+			return nil, fmt.Errorf("Invalid finish query of scope [%v]: %v", path, err)
+		}
+		if hasPlaceholders(config.FinishQuery) == false {
+			result.rxStop = rx
+		}
+	}
+
+	for j, q := range config.SearchQuery {
+		rx, err := compileQuery(q, nil, q.Multiline, timeout)
+		if err != nil {
+			return nil, fmt.Errorf("Invalid #%v search query of scope [%v]: %v", j, path, err)
+		}
+		result.groups = append(result.groups, groupNames(rx)...)
+		if hasPlaceholders(q.Pattern) == true {
+			rx = nil
+		}
+		result.search = append(result.search, rx)
+	}
+
+	for j, q := range config.ExcludeQuery {
+		rx, err := compileQuery(q, nil, false, timeout)
+		if err != nil {
+			return nil, fmt.Errorf("Invalid #%v exclude query of scope [%v]: %v", j, path, err)
+		}
+		if hasPlaceholders(q.Pattern) == true {
+			rx = nil
+		}
+		result.exclude = append(result.exclude, rx)
+	}
+
+	for j, c := range config.Scopes {
+		child, err := compileScope(c, fmt.Sprintf("%v.%v", path, j), timeout)
+		if err != nil {
+			return nil, err
+		}
+		result.children = append(result.children, child)
+	}
+
+	return result, nil
+}
+
+// compileQuery compiles query with placeholders replaced by values captured by start query.
+// Timeout of scan is used when query does not define own timeout.
+func compileQuery(q common.Query, captures map[string]string, multiline bool, timeout time.Duration) (matcher, error) {
+	return compileExpanded(q.Pattern, captures, q.Engine, q.Options, multiline, queryTimeout(q.MatchTimeout, timeout))
+}
+
+// queryTimeout returns own timeout of query (in milliseconds) or timeout of scan when query does not define it
+func queryTimeout(milliseconds int, timeout time.Duration) time.Duration {
+	if milliseconds > 0 {
+		return time.Duration(milliseconds) * time.Millisecond
+	}
+	return timeout
+}
+
+// compileExpanded compiles pattern with placeholders replaced by captured values (see expandQuery).
+// Pattern of QueryEngineAhoCorasick is split into literals before placeholders are replaced,
+// so captured value is always part of single literal (even when it contains separator of literals).
+func compileExpanded(pattern string, captures map[string]string, engine common.QueryEngine, options string, multiline bool, timeout time.Duration) (matcher, error) {
+	if (engine.OrDefault() == common.QueryEngineAhoCorasick) && (hasPlaceholders(pattern) == true) {
+		literals := strings.Split(pattern, "|")
+		for i, l := range literals {
+			literals[i] = expandQuery(l, captures, engine)
+		}
+		return newAhoCorasickMatcher(literals, ignoresCase(options)), nil
+	}
+	return compileMatcher(expandQuery(pattern, captures, engine), engine, options, multiline, timeout)
+}
+
+// queryMatchers returns matchers of queries of open scope. Query which is not compiled yet
+// (it refers to named groups of start query) is compiled with captured values.
+func queryMatchers(queries []common.Query, compiled []matcher, captures map[string]string, multiline bool, timeout time.Duration) ([]matcher, error) {
+	result := make([]matcher, len(queries))
+	for j, q := range queries {
+		if compiled[j] != nil {
+			result[j] = compiled[j]
+			continue
+		}
+
+		rx, err := compileQuery(q, captures, multiline && q.Multiline, timeout)
+		if err != nil {
+			return nil, fmt.Errorf("Invalid query [%v]: %v", q.Label(), err)
+		}
+		result[j] = rx
+	}
+	return result, nil
+}
+
+func newScopeMachine(scope *compiledScope, logger *zerolog.Logger, events *Events, collect bool, fileName string, depth int, timeouts *fileTimeouts) *scopeMachine {
+	m := &scopeMachine{
+		scope:    scope,
+		logger:   logger,
+		events:   events,
+		collect:  collect,
+		fileName: fileName,
+		depth:    depth,
+		timeouts: timeouts,
+	}
+
+	if collect == true {
+		m.result.Scopes = []common.ScopeSummary{}
+	}
+
+	for _, c := range scope.children {
+		m.children = append(m.children, newScopeMachine(c, logger, events, collect, fileName, depth+1, timeouts))
+	}
+
+	return m
+}
+
+// feed processes next line of file. When scan is false end of file is reached and
+// line (and index) still contains last line of file.
+func (m *scopeMachine) feed(line string, index int, scan bool) {
+
+	if scan == false {
+		// last line has been already processed, so only scope which is still open is closed
+		if m.scopeIsOpen == true {
+			m.endScope(eofLine, m.lastLine(index))
+		}
+		return
+	}
+
+	sc := &m.scope.config
+
+	isStart := m.isStart(line, index)
+
+	if (isStart == true) && (m.scopeIsOpen == false) {
+
+		m.beginScope(line, index)
+
+	} else if (isStart == true) && (sc.StartQueryCloseScope == true) && (m.scopeIsOpen == true) {
+
+		m.logger.Info().Msg("End scope because of StartQueryCloseScope flag.")
+
+		m.addLine(line, index, finishScopeMark)
+		m.endScope("", index)
+		m.beginScope(line, index)
+
+	} else {
+		finished, included := false, true
+		if m.scopeIsOpen == true {
+			finished, included = m.closer.next(line)
+		}
+
+		if (finished == true) && (included == false) {
+
+			// scope is finished in previous line, but current line can start new scope
+			m.endScope("", m.lastLine(index-1))
+			m.feed(line, index, scan)
+
+		} else if finished == true {
+
+			m.addLine(line, index, finishScopeMark)
+			if m.passesEdgeLines() == true {
+				for _, c := range m.children {
+					c.feed(line, index, scan)
+				}
+			}
+			m.endScope("", index)
+
+		} else {
+			if m.scopeIsOpen == true {
+
+				m.logger.Trace().Msgf("|%v|", line)
+
+				m.addLine(line, index, notMatchedMark)
+
+				for _, c := range m.children {
+					c.feed(line, index, scan)
+				}
+			}
+		}
+	}
+}
+
+// isStart returns true when line starts scope. Scope in ScopeModeFile starts in first line of file.
+func (m *scopeMachine) isStart(line string, index int) bool {
+	if m.scope.rxStart == nil {
+		return (index == 1) && (m.scopeIsOpen == false)
+	}
+	ok, err := checkIfBeginScope(line, m.scope.rxStart, false)
+	m.timeouts.add(index, err)
+	return ok
+}
+
+// newCloser creates closer for scope which has been just opened
+func (m *scopeMachine) newCloser() scopeCloser {
+
+	sc := &m.scope.config
+
+	switch sc.ScopeMode() {
+	case common.ScopeModeBalanced:
+		return &balancedCloser{cfg: sc.BalancedConfig()}
+	case common.ScopeModeIndent:
+		return &indentCloser{tabWidth: sc.TabWidthOrDefault()}
+	case common.ScopeModeFile:
+		return &fileCloser{}
+	case common.ScopeModeWindow:
+		return &windowCloser{left: sc.Lines}
+	case common.ScopeModeBlank:
+		return &blankCloser{}
+	default:
+		rx := m.scope.rxStop
+		if rx == nil {
+			m.logger.Trace().Msgf("Finish query of scope [%v]: %v %v", sc.Name, sc.FinishQuery, m.scopeSummary.Captures)
+
+			var err error
+			rx, err = compileExpanded(sc.FinishQuery, m.scopeSummary.Captures, sc.FinishQueryEngine, sc.FinishQueryOptions, false, queryTimeout(sc.FinishQueryTimeout, m.scope.timeout))
+			if err != nil {
+				// scope is finished at the end of file (or parent scope)
+				m.logger.Error().Msgf("Invalid finish query of scope [%v] in [%v][%v]: %v", sc.Name, m.fileName, m.scopeSummary.Started, err)
+				return &fileCloser{}
+			}
+		}
+		return &regexCloser{rx: rx, timeouts: m.timeouts}
+	}
+}
+
+// reset prepares machine for new scope of parent
+func (m *scopeMachine) reset(parents []common.ScopeParent) {
+	m.parents = parents
+	m.scopeIsOpen = false
+	m.scopeSummary = common.ScopeSummary{}
+}
+
+// finish closes open scope of machine (and its children) because parent scope is closed
+func (m *scopeMachine) finish(mark string, index int) {
+	if m.scopeIsOpen == true {
+		m.endScope(mark, m.lastLine(index))
+	}
+}
+
+// addLine appends line of file to content of open scope. Mark describes line in html content.
+func (m *scopeMachine) addLine(line string, index int, mark string) {
+	m.scopeSummary.Content = append(m.scopeSummary.Content, line)
+	tmp := fmt.Sprintf(formatContentHTML, index, mark, line)
+	m.scopeSummary.ContentAsHTML = append(m.scopeSummary.ContentAsHTML, html.EscapeString(tmp))
+}
+
+// lastLine returns index of last line of open scope which is finished without finish line in line index or before it
+// (last line passed to scope or line before trailing lines, see trailingCloser).
+// Lines after last line are removed from content of scope, start line is always kept.
+func (m *scopeMachine) lastLine(index int) int {
+
+	started := m.scopeSummary.Started
+
+	last := started + len(m.scopeSummary.Content) - 1
+	if t, ok := m.closer.(trailingCloser); ok == true {
+		last -= t.trailing()
+	}
+	if last < index {
+		index = last
+	}
+	if index < started {
+		index = started
+	}
+
+	if n := index - started + 1; len(m.scopeSummary.Content) > n {
+		m.scopeSummary.Content = m.scopeSummary.Content[:n]
+		m.scopeSummary.ContentAsHTML = m.scopeSummary.ContentAsHTML[:n]
+	}
+
+	return index
+}
+
+func (m *scopeMachine) beginScope(line string, index int) {
+
+	scopeName := m.scope.config.Name
+
+	m.logger.Trace().Msgf("Begin scope [%v] in line [%v]", scopeName, index)
+	m.scopeIsOpen = true
+	m.scopeSummary = common.ScopeSummary{
+		Name:     scopeName,
+		FileName: m.fileName,
+		Started:  index,
+		Finished: 0,
+		Depth:    m.depth,
+		Severity: m.scope.config.Severity,
+		Parents:  m.parents,
+		Matches:  nil,
+		Content:  nil,
+	}
+	captures, err := findCaptures(m.scope.rxStart, line)
+	m.timeouts.add(index, err)
+	m.scopeSummary.Captures = captures
+	m.closer = m.newCloser()
+
+	m.addLine(line, index, startScopeMark)
+
+	m.events.scopeOpened(m.scopeSummary)
+
+	if len(m.children) > 0 {
+		parents := make([]common.ScopeParent, len(m.parents), len(m.parents)+1)
+		copy(parents, m.parents)
+		parents = append(parents, common.ScopeParent{
+			Name:    scopeName,
+			Started: index,
+		})
+
+		for _, c := range m.children {
+			c.reset(parents)
+			if m.passesEdgeLines() == true {
+				c.feed(line, index, true)
+			}
+		}
+	}
+
+	if m.closer.begin(line) == true {
+		// start line is finish line too (it is already in content)
+		m.endScope("", index)
+	}
+}
+
+// passesEdgeLines returns true when start and finish lines of scope are passed to child scopes. They are passed
+// in modes where they are ordinary lines of scope (ScopeModeFile, ScopeModeWindow and ScopeModeBlank),
+// but not in modes where they open and close scope like delimiters (ScopeModeRegex, ScopeModeBalanced and ScopeModeIndent).
+func (m *scopeMachine) passesEdgeLines() bool {
+	switch m.scope.config.ScopeMode() {
+	case common.ScopeModeFile, common.ScopeModeWindow, common.ScopeModeBlank:
+		return true
+	default:
+		return false
+	}
+}
+
+// endScope closes scope in line index (finish line, if any, is already in content). When mark is not empty then scope
+// is closed without finish line (end of file or end of parent scope) and mark is added to html content only,
+// so queries never match it.
+func (m *scopeMachine) endScope(mark string, index int) {
+
+	scopeName := m.scope.config.Name
+
+	// child scopes can not be longer than parent scope
+	childMark := mark
+	if childMark == "" {
+		childMark = parentEndLine
+	}
+	for _, c := range m.children {
+		c.finish(childMark, index)
+	}
+
+	m.logger.Trace().Msgf("End scope [%v] in line [%v]", scopeName, index)
+
+	m.scopeIsOpen = false
+	m.scopeSummary.Finished = index
+
+	if mark != "" {
+		m.logger.Trace().Msgf("End of scope without finish line %v", mark)
+
+		tmp := html.EscapeString(mark)
+		m.scopeSummary.ContentAsHTML = append(m.scopeSummary.ContentAsHTML, tmp)
+	}
+
+	scopeSummaryWithConfig := common.ScopeSummaryWithConfig{
+		ScopeSummary: m.scopeSummary,
+		ScopeConfig:  m.scope.config,
+	}
+
+	s, accepted, err := findMatchesInScope(scopeSummaryWithConfig, m.scope, m.timeouts, *m.logger)
+	if err != nil {
+		m.logger.Error().Msgf("Search in scope [%v] in [%v][%v] failed: %v", scopeName, m.fileName, m.scopeSummary.Started, err)
+	} else {
+		m.scopeSummary.Matches = append(m.scopeSummary.Matches, s.Matches...)
+		m.scopeSummary.Reason = s.Reason
+		m.scopeSummary.Counts = s.Counts
+
+		if accepted == true {
+			m.logger.Trace().Msg("Update summary")
+			if m.collect == true {
+				m.result.Scopes = append(m.result.Scopes, m.scopeSummary)
+			}
+			m.result.AllMatches++
+		}
+	}
+
+	m.events.scopeClosed(m.scopeSummary)
+}
+
+// collectResults appends results of machine and its children (in order of configuration) into file summary
+func (m *scopeMachine) collectResults(fileScopeSummary *common.FileScopeSummary) {
+	if m.collect == true {
+		fileScopeSummary.Scopes = append(fileScopeSummary.Scopes, m.result.Scopes...)
+	}
+	fileScopeSummary.AllMatches += m.result.AllMatches
+
+	for _, c := range m.children {
+		c.collectResults(fileScopeSummary)
+	}
+}
@@ -0,0 +1,531 @@
+package scanner
+
+import (
+	"bufio"
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	common "gorex/pkg/common"
+
+	"github.com/rs/zerolog"
+)
+
+// -----------------------------------------------------------------------------
+// types
+// -----------------------------------------------------------------------------
+
+// foundScope contains fields of found scope compared by tests
+type foundScope struct {
+	Name     string
+	Started  int
+	Finished int
+	Content  []string
+	Matches  []int
+}
+
+// scopeTest is test case of scopes found in content of single file
+type scopeTest struct {
+	name    string
+	scopes  []common.ScopeConfig
+	content string
+	want    []foundScope
+}
+
+// -----------------------------------------------------------------------------
+// functions
+// -----------------------------------------------------------------------------
+
+// foundScopes returns compared fields of scopes
+func foundScopes(scopes []common.ScopeSummary) []foundScope {
+	result := []foundScope{}
+	for _, s := range scopes {
+		f := foundScope{
+			Name:     s.Name,
+			Started:  s.Started,
+			Finished: s.Finished,
+			Content:  s.Content,
+		}
+		for _, m := range s.Matches {
+			f.Matches = append(f.Matches, m.Index)
+		}
+		result = append(result, f)
+	}
+	return result
+}
+
+// summaryScopes returns compared fields of scopes found in every file of summary (by name of file)
+func summaryScopes(summary common.ScanSummary) map[string][]foundScope {
+	result := make(map[string][]foundScope)
+	for _, f := range summary.Summary {
+		result[filepath.Base(f.FileName)] = foundScopes(f.Scopes)
+	}
+	return result
+}
+
+// scanContent returns compared fields of scopes (of every level) found in content of single file
+func scanContent(tb testing.TB, scopes []common.ScopeConfig, content string) []foundScope {
+	tb.Helper()
+
+	summary := scanFiles(tb, common.ScanConfig{Scopes: scopes}, map[string]string{"a.txt": content})
+
+	result := foundScopes(nil)
+	for _, f := range summary.Summary {
+		result = append(result, foundScopes(f.Scopes)...)
+	}
+	return result
+}
+
+// runScopeTests compares scopes found in content of every test case with expected scopes
+func runScopeTests(t *testing.T, tests []scopeTest) {
+	t.Helper()
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := scanContent(t, tt.scopes, tt.content); reflect.DeepEqual(got, tt.want) == false {
+				t.Errorf("found %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+// scanFileMultiPass finds scopes in content of file the way scan worked before single pass:
+// file is read once for every scope configuration (only top level scopes in ScopeModeRegex).
+// Mark of end of file is added to html content only (as scan does now).
+func scanFileMultiPass(tb testing.TB, config common.ScanConfig, content string) []common.ScopeSummary {
+	tb.Helper()
+
+	logger := zerolog.Nop()
+	var result []common.ScopeSummary
+
+	for i, sc := range config.Scopes {
+
+		compiled, err := compileScope(sc, fmt.Sprint(i), 0)
+		if err != nil {
+			tb.Fatal(err)
+		}
+		timeouts := newFileTimeouts(&logger, "", 0)
+
+		scopeIsOpen := false
+		var scope common.ScopeSummary
+
+		beginScope := func(line string, index int) {
+			scopeIsOpen = true
+			scope = common.ScopeSummary{
+				Name:          sc.Name,
+				Started:       index,
+				Content:       []string{line},
+				ContentAsHTML: []string{fmt.Sprintf(formatContentHTML, index, startScopeMark, line)},
+			}
+		}
+
+		endScope := func(scan bool, line string, index int) {
+			scopeIsOpen = false
+			scope.Finished = index
+			if scan == false {
+				scope.ContentAsHTML = append(scope.ContentAsHTML, eofLine)
+			} else if line != "" {
+				scope.Content = append(scope.Content, line)
+				scope.ContentAsHTML = append(scope.ContentAsHTML, fmt.Sprintf(formatContentHTML, index, finishScopeMark, line))
+			}
+
+			s, accepted, err := findMatchesInScope(common.ScopeSummaryWithConfig{ScopeSummary: scope, ScopeConfig: sc}, compiled, timeouts, logger)
+			if err != nil {
+				tb.Fatal(err)
+			}
+			if accepted == true {
+				result = append(result, s)
+			}
+		}
+
+		scanner := bufio.NewScanner(strings.NewReader(content))
+		index := 0
+		var scan bool = true
+		var line string
+		for scan {
+			scan = scanner.Scan()
+
+			if scan == true {
+				line = scanner.Text()
+				index++
+			}
+
+			isStart, _ := compiled.rxStart.matchString(line)
+			isFinish, _ := compiled.rxStop.matchString(line)
+
+			if (isStart == true) && (scopeIsOpen == false) {
+				beginScope(line, index)
+			} else if (isStart == true) && (sc.StartQueryCloseScope == true) && (scopeIsOpen == true) {
+				endScope(scan, line, index)
+				beginScope(line, index)
+			} else if (scopeIsOpen == true) && ((isFinish == true) || (scan == false)) {
+				endScope(scan, line, index)
+			} else if scopeIsOpen == true {
+				scope.Content = append(scope.Content, line)
+				scope.ContentAsHTML = append(scope.ContentAsHTML, fmt.Sprintf(formatContentHTML, index, notMatchedMark, line))
+			}
+		}
+	}
+
+	return result
+}
+
+// exampleFiles returns files of example folder
+func exampleFiles(tb testing.TB) map[string]string {
+	tb.Helper()
+
+	paths, err := filepath.Glob(filepath.Join("..", "..", "example", "*.txt"))
+	if err != nil {
+		tb.Fatal(err)
+	}
+
+	result := make(map[string]string)
+	for _, p := range paths {
+		content, err := ioutil.ReadFile(p)
+		if err != nil {
+			tb.Fatal(err)
+		}
+		result[filepath.Base(p)] = string(content)
+	}
+	return result
+}
+
+// exampleConfig returns configuration of example
+func exampleConfig(tb testing.TB) common.ScanConfig {
+	tb.Helper()
+
+	config, err := common.ReadScopeConfiguration(filepath.Join("..", "..", "example.json"))
+	if err != nil {
+		tb.Fatal(err)
+	}
+	return config
+}
+
+// TestScopeMachine_SinglePass compares scopes found by single pass of file with scopes
+// found by reading file once for every scope configuration
+func TestScopeMachine_SinglePass(t *testing.T) {
+
+	command := []common.Query{{Pattern: `^\s*COMMAND\=.*$`}}
+
+	tests := []struct {
+		name   string
+		scopes []common.ScopeConfig
+		files  map[string]string
+	}{
+		{
+			name:   "example",
+			scopes: exampleConfig(t).Scopes,
+			files:  exampleFiles(t),
+		},
+		{
+			name: "start query closes scope",
+			scopes: []common.ScopeConfig{
+				{Name: "s", StartQuery: `^BEGIN$`, FinishQuery: `^END$`, StartQueryCloseScope: true, SearchQuery: command},
+			},
+			files: map[string]string{
+				"a.txt": "BEGIN\nCOMMAND=1\nBEGIN\nCOMMAND=2\nEND\nBEGIN\nx\nEND\n",
+				"b.txt": "BEGIN\nCOMMAND=1\nBEGIN\n",
+			},
+		},
+		{
+			name: "start query does not close scope",
+			scopes: []common.ScopeConfig{
+				{Name: "s", StartQuery: `^BEGIN$`, FinishQuery: `^END$`, SearchQuery: command},
+			},
+			files: map[string]string{
+				"a.txt": "BEGIN\nCOMMAND=1\nBEGIN\nCOMMAND=2\nEND\nCOMMAND=3\nEND\n",
+			},
+		},
+		{
+			name: "scope closed at end of file",
+			scopes: []common.ScopeConfig{
+				{Name: "s", StartQuery: `^BEGIN$`, FinishQuery: `^END$`, SearchQuery: command},
+			},
+			files: map[string]string{
+				"a.txt": "BEGIN\nCOMMAND=1\nEND\nBEGIN\nCOMMAND=2",
+				"b.txt": "BEGIN\nCOMMAND=1\n\n",
+				"c.txt": "COMMAND=1\nBEGIN\n",
+			},
+		},
+		{
+			name: "finish line equal to start line",
+			scopes: []common.ScopeConfig{
+				{Name: "s", StartQuery: `^BEGIN`, FinishQuery: `END$`, SearchQuery: command},
+				{Name: "t", StartQuery: `^BEGIN`, FinishQuery: `END$`, StartQueryCloseScope: true, SearchQuery: command},
+			},
+			files: map[string]string{
+				"a.txt": "BEGIN END\nCOMMAND=1\nEND\n",
+				"b.txt": "BEGIN\nCOMMAND=1\nBEGIN END\nCOMMAND=2\nEND\n",
+			},
+		},
+		{
+			name: "many configurations",
+			scopes: []common.ScopeConfig{
+				{Name: "all", StartQuery: `^BEGIN$`, FinishQuery: `^END$`, SearchQuery: []common.Query{{Pattern: `COMMAND`}, {Pattern: `=2`}}},
+				{Name: "any", StartQuery: `^BEGIN$`, FinishQuery: `^END$`, SearchQuery: []common.Query{{Pattern: `=1`}, {Pattern: `=3`}}, SearchQueryMode: common.SearchQueryOperatorAny},
+				{Name: "order", StartQuery: `COMMAND`, FinishQuery: `^END$`, SearchQuery: []common.Query{{Pattern: `=2`}, {Pattern: `=3`}}, SearchQueryMode: common.SearchQueryOperatorStrictOrder},
+			},
+			files: map[string]string{
+				"a.txt": "BEGIN\nCOMMAND=1\nCOMMAND=2\nCOMMAND=3\nEND\nBEGIN\nCOMMAND=3\nCOMMAND=2\nEND\n",
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+
+			config := common.ScanConfig{Scopes: tt.scopes}
+
+			want := make(map[string][]foundScope)
+			for name, content := range tt.files {
+				if scopes := scanFileMultiPass(t, config, content); len(scopes) > 0 {
+					want[name] = foundScopes(scopes)
+				}
+			}
+
+			if len(want) == 0 {
+				t.Fatal("no scopes found by multi pass")
+			}
+
+			got := summaryScopes(scanFiles(t, config, tt.files))
+
+			if reflect.DeepEqual(got, want) == false {
+				t.Errorf("single pass found %+v, want %+v", got, want)
+			}
+		})
+	}
+}
+
+// TestScopeMachine_EndOfFile checks that last line of file is not processed again at the end of file
+func TestScopeMachine_EndOfFile(t *testing.T) {
+
+	function := []common.Query{{Pattern: `func`}}
+
+	runScopeTests(t, []scopeTest{
+		{
+			name:    "balanced scope in last line",
+			scopes:  []common.ScopeConfig{{Name: "f", StartQuery: `^func`, Mode: common.ScopeModeBalanced, SearchQuery: function}},
+			content: "package a\nfunc a() {}",
+			want:    []foundScope{{Name: "f", Started: 2, Finished: 2, Content: []string{"func a() {}"}, Matches: []int{2}}},
+		},
+		{
+			name:    "balanced scope in last line closes scope",
+			scopes:  []common.ScopeConfig{{Name: "f", StartQuery: `^func`, Mode: common.ScopeModeBalanced, StartQueryCloseScope: true, SearchQuery: function}},
+			content: "func a() {}\nfunc b() {}\n",
+			want: []foundScope{
+				{Name: "f", Started: 1, Finished: 1, Content: []string{"func a() {}"}, Matches: []int{1}},
+				{Name: "f", Started: 2, Finished: 2, Content: []string{"func b() {}"}, Matches: []int{2}},
+			},
+		},
+		{
+			name:    "window scope in last line",
+			scopes:  []common.ScopeConfig{{Name: "f", StartQuery: `^func`, Mode: common.ScopeModeWindow, Lines: 2, SearchQuery: function}},
+			content: "func a() {",
+			want:    []foundScope{{Name: "f", Started: 1, Finished: 1, Content: []string{"func a() {"}, Matches: []int{1}}},
+		},
+	})
+}
+
+// TestScopeMachine_Indent checks that trailing blank lines do not belong to scope in ScopeModeIndent
+func TestScopeMachine_Indent(t *testing.T) {
+
+	scope := common.ScopeConfig{Name: "i", StartQuery: `:$`, Mode: common.ScopeModeIndent, SearchQuery: []common.Query{{Pattern: `b`}}}
+
+	parent := scope
+	parent.SearchQuery = nil
+	parent.SearchQueryMode = common.SearchQueryOperatorAny
+	parent.Scopes = []common.ScopeConfig{{Name: "c", StartQuery: `b`, FinishQuery: `^END$`, SearchQuery: []common.Query{{Pattern: `b`}}}}
+
+	runScopeTests(t, []scopeTest{
+		{
+			name:    "blank lines before next block",
+			scopes:  []common.ScopeConfig{scope},
+			content: "a:\n  b\n\n\nc:\n",
+			want:    []foundScope{{Name: "i", Started: 1, Finished: 2, Content: []string{"a:", "  b"}, Matches: []int{2}}},
+		},
+		{
+			name:    "blank lines inside block",
+			scopes:  []common.ScopeConfig{scope},
+			content: "a:\n  b\n\n  d\n\nc:\n",
+			want:    []foundScope{{Name: "i", Started: 1, Finished: 4, Content: []string{"a:", "  b", "", "  d"}, Matches: []int{2}}},
+		},
+		{
+			name:    "blank lines at end of file",
+			scopes:  []common.ScopeConfig{scope},
+			content: "a:\n  b\n\n \n",
+			want:    []foundScope{{Name: "i", Started: 1, Finished: 2, Content: []string{"a:", "  b"}, Matches: []int{2}}},
+		},
+		{
+			name:    "child scope",
+			scopes:  []common.ScopeConfig{parent},
+			content: "a:\n  b\n\n\nc:\n",
+			want:    []foundScope{{Name: "c", Started: 2, Finished: 2, Content: []string{"  b"}, Matches: []int{2}}},
+		},
+	})
+}
+
+// TestScopeMachine_Content checks that content of scope contains lines from start line to finish line
+// and that marks of scope closed without finish line are not matched by queries
+func TestScopeMachine_Content(t *testing.T) {
+
+	runScopeTests(t, []scopeTest{
+		{
+			name:    "mark of end of file",
+			scopes:  []common.ScopeConfig{{Name: "f", Mode: common.ScopeModeFile, SearchQuery: []common.Query{{Pattern: `EOF|PARENT`}}}},
+			content: "a\nb\n",
+			want:    []foundScope{},
+		},
+		{
+			name:    "mark of end of file and multiline query",
+			scopes:  []common.ScopeConfig{{Name: "f", Mode: common.ScopeModeFile, SearchQuery: []common.Query{{Pattern: `b\n\[EOF\]`, Multiline: true}}}},
+			content: "a\nb\n",
+			want:    []foundScope{},
+		},
+		{
+			name: "mark of end of parent scope",
+			scopes: []common.ScopeConfig{{
+				Name: "p", StartQuery: `^BEGIN$`, FinishQuery: `^END$`, SearchQueryMode: common.SearchQueryOperatorAny,
+				Scopes: []common.ScopeConfig{{Name: "c", StartQuery: `^x`, FinishQuery: `^y`, SearchQuery: []common.Query{{Pattern: `PARENT`}}}},
+			}},
+			content: "BEGIN\nx\nEND\n",
+			want:    []foundScope{},
+		},
+		{
+			name:    "blank finish line",
+			scopes:  []common.ScopeConfig{{Name: "w", StartQuery: `^x`, Mode: common.ScopeModeWindow, Lines: 2, SearchQuery: []common.Query{{Pattern: `x`}}}},
+			content: "x\na\n\nb\n",
+			want:    []foundScope{{Name: "w", Started: 1, Finished: 3, Content: []string{"x", "a", ""}, Matches: []int{1}}},
+		},
+	})
+}
+
+// TestScopeMachine_EdgeLines checks which start and finish lines of parent scope are passed to child scopes
+func TestScopeMachine_EdgeLines(t *testing.T) {
+
+	child := common.ScopeConfig{Name: "c", StartQuery: `BEGIN$`, FinishQuery: `END$`, SearchQuery: []common.Query{{Pattern: `COMMAND`}}}
+
+	parent := func(mode common.ScopeMode, start string, lines int) []common.ScopeConfig {
+		return []common.ScopeConfig{{
+			Name: "p", Mode: mode, StartQuery: start, FinishQuery: `^$`, Lines: lines,
+			SearchQueryMode: common.SearchQueryOperatorAny, Scopes: []common.ScopeConfig{child},
+		}}
+	}
+
+	found := []foundScope{{Name: "c", Started: 1, Finished: 3, Content: []string{"BEGIN", "COMMAND", "END"}, Matches: []int{2}}}
+
+	runScopeTests(t, []scopeTest{
+		{
+			name:    "child scope in first line of file scope",
+			scopes:  parent(common.ScopeModeFile, "", 0),
+			content: "BEGIN\nCOMMAND\nEND",
+			want:    found,
+		},
+		{
+			name:    "child scope in start line of window scope",
+			scopes:  parent(common.ScopeModeWindow, `^BEGIN$`, 2),
+			content: "BEGIN\nCOMMAND\nEND\nx\n",
+			want:    found,
+		},
+		{
+			name:    "child scope in start line of blank scope",
+			scopes:  parent(common.ScopeModeBlank, `^BEGIN$`, 0),
+			content: "BEGIN\nCOMMAND\nEND\n\nx\n",
+			want:    found,
+		},
+		{
+			name:    "child scope in start line of regex scope",
+			scopes:  parent(common.ScopeModeRegex, `^BEGIN$`, 0),
+			content: "BEGIN\nCOMMAND\nEND\n\nx\n",
+			want:    []foundScope{},
+		},
+		{
+			name:    "child scope in start line of indent scope",
+			scopes:  parent(common.ScopeModeIndent, `^BEGIN$`, 0),
+			content: "BEGIN\n  COMMAND\n  END\nx\n",
+			want:    []foundScope{},
+		},
+		{
+			name:    "child scope finished in finish line of window scope",
+			scopes:  parent(common.ScopeModeWindow, `^x$`, 3),
+			content: "x\nBEGIN\nCOMMAND\nEND\nCOMMAND\n",
+			want:    []foundScope{{Name: "c", Started: 2, Finished: 4, Content: []string{"BEGIN", "COMMAND", "END"}, Matches: []int{3}}},
+		},
+	})
+}
+
+// TestScopeMachine_Nested checks that child scopes are found only inside parent scope, that they are closed
+// with parent scope and that they contain depth and chain of parent scopes
+func TestScopeMachine_Nested(t *testing.T) {
+
+	call := []common.Query{{Pattern: `CALL`}}
+
+	config := common.ScanConfig{Scopes: []common.ScopeConfig{{
+		Name: "p", StartQuery: `^BEGIN$`, FinishQuery: `^END$`, SearchQueryMode: common.SearchQueryOperatorAny,
+		Scopes: []common.ScopeConfig{{
+			Name: "c", StartQuery: `^\s*FUNC$`, FinishQuery: `^\s*ENDFUNC$`, SearchQuery: call,
+			Scopes: []common.ScopeConfig{{
+				Name: "g", StartQuery: `^\s*IF$`, FinishQuery: `^\s*ENDIF$`, SearchQuery: call,
+			}},
+		}},
+	}}}
+
+	content := strings.Join([]string{
+		"CALL",
+		"BEGIN",
+		"  FUNC",
+		"    IF",
+		"      CALL",
+		"    ENDIF",
+		"    CALL",
+		"  ENDFUNC",
+		"  IF",
+		"    CALL",
+		"  ENDIF",
+		"  FUNC",
+		"    IF",
+		"      CALL",
+		"END",
+		"FUNC",
+		"  CALL",
+		"ENDFUNC",
+	}, "\n")
+
+	type nestedScope struct {
+		Name     string
+		Started  int
+		Finished int
+		Depth    int
+		Parents  []common.ScopeParent
+	}
+
+	p := common.ScopeParent{Name: "p", Started: 2}
+	want := []nestedScope{
+		{Name: "c", Started: 3, Finished: 8, Depth: 1, Parents: []common.ScopeParent{p}},
+		{Name: "c", Started: 12, Finished: 14, Depth: 1, Parents: []common.ScopeParent{p}},
+		{Name: "g", Started: 4, Finished: 6, Depth: 2, Parents: []common.ScopeParent{p, {Name: "c", Started: 3}}},
+		{Name: "g", Started: 13, Finished: 14, Depth: 2, Parents: []common.ScopeParent{p, {Name: "c", Started: 12}}},
+	}
+
+	summary := scanFiles(t, config, map[string]string{"a.txt": content})
+	if len(summary.Summary) != 1 {
+		t.Fatalf("found scopes in [%v] files, want [1]", len(summary.Summary))
+	}
+
+	var got []nestedScope
+	for _, s := range summary.Summary[0].Scopes {
+		got = append(got, nestedScope{Name: s.Name, Started: s.Started, Finished: s.Finished, Depth: s.Depth, Parents: s.Parents})
+	}
+
+	if reflect.DeepEqual(got, want) == false {
+		t.Errorf("found %+v, want %+v", got, want)
+	}
+
+	// scope closed with parent scope has mark of end of parent scope in html content only
+	last := summary.Summary[0].Scopes[1]
+	if html := last.ContentAsHTML[len(last.ContentAsHTML)-1]; strings.Contains(html, "PARENT") == false {
+		t.Errorf("last line of html content [%v], want mark of end of parent scope", html)
+	}
+}
@@ -0,0 +1,89 @@
+package scanner
+
+import (
+	"sort"
+	"strings"
+
+	common "gorex/pkg/common"
+)
+
+// -----------------------------------------------------------------------------
+// functions
+// -----------------------------------------------------------------------------
+
+// findQueryMatches returns matches of query in content of scope grouped by offset of first line of match.
+// Query which is not multiline is matched line by line (one match per line).
+// Timeout of multiline query is counted in first line of scope.
+func findQueryMatches(content []string, started int, rx matcher, q common.Query, timeouts *fileTimeouts) map[int][]common.MatchLine {
+
+	result := make(map[int][]common.MatchLine)
+
+	if q.Multiline == false {
+		for i, line := range content {
+			m, err := rx.find(line)
+			timeouts.add(i+started, err)
+			if m == nil {
+				continue
+			}
+			result[i] = append(result[i], common.MatchLine{
+				Index:     i + started,
+				Line:      line,
+				Query:     q.Label(),
+				Column:    m.index + 1,
+				EndIndex:  i + started,
+				EndColumn: m.index + m.length + 1,
+				Captures:  m.groups,
+			})
+		}
+		return result
+	}
+
+	// offsets (in runes) of first character of every line in joined content
+	lineStarts := make([]int, len(content))
+	offset := 0
+	for i, line := range content {
+		lineStarts[i] = offset
+		offset += len([]rune(line)) + 1
+	}
+
+	matches, err := rx.findAll(strings.Join(content, "\n"))
+	timeouts.add(started, err)
+
+	for _, m := range matches {
+
+		first := lineOf(lineStarts, m.index)
+		last := first
+		if m.length > 0 {
+			last = lineOf(lineStarts, m.index+m.length-1)
+		}
+
+		result[first] = append(result[first], common.MatchLine{
+			Index:     first + started,
+			Line:      strings.Join(content[first:last+1], "\n"),
+			Query:     q.Label(),
+			Column:    m.index - lineStarts[first] + 1,
+			EndIndex:  last + started,
+			EndColumn: m.index + m.length - lineStarts[last] + 1,
+			Captures:  m.groups,
+		})
+	}
+
+	return result
+}
+
+// lineOf returns offset of line which contains character at given position of joined content
+func lineOf(lineStarts []int, position int) int {
+	return sort.Search(len(lineStarts), func(i int) bool {
+		return lineStarts[i] > position
+	}) - 1
+}
+
+// sortedOffsets returns offsets of lines (keys of matches) in ascending order
+func sortedOffsets(matches map[int][]common.MatchLine) []int {
+	var result []int
+	for i := range matches {
+		result = append(result, i)
+	}
+	sort.Ints(result)
+	return result
+}
@@ -0,0 +1,109 @@
+package scanner
+
+import (
+	"reflect"
+	"testing"
+
+	common "gorex/pkg/common"
+
+	"github.com/rs/zerolog"
+)
+
+// -----------------------------------------------------------------------------
+// functions
+// -----------------------------------------------------------------------------
+
+// TestFindQueryMatches_Multiline checks lines and columns (in characters) of matches of multiline query
+// found in joined content of scope which starts in line 10
+func TestFindQueryMatches_Multiline(t *testing.T) {
+
+	type position struct {
+		Index, Column, EndIndex, EndColumn int
+		Line                               string
+	}
+
+	tests := []struct {
+		name    string
+		pattern string
+		engine  common.QueryEngine
+		content []string
+		want    map[int][]position
+	}{
+		{
+			name:    "match across lines",
+			pattern: `ż\nż`,
+			content: []string{"aż", "żb", "c"},
+			want:    map[int][]position{0: {{Index: 10, Column: 2, EndIndex: 11, EndColumn: 2, Line: "aż\nżb"}}},
+		},
+		{
+			name:    "match across three lines",
+			pattern: `a\nb\nc`,
+			content: []string{"xa", "b", "cy"},
+			want:    map[int][]position{0: {{Index: 10, Column: 2, EndIndex: 12, EndColumn: 2, Line: "xa\nb\ncy"}}},
+		},
+		{
+			name:    "match after multibyte characters",
+			pattern: `b`,
+			content: []string{"żółw", "ąb"},
+			want:    map[int][]position{1: {{Index: 11, Column: 2, EndIndex: 11, EndColumn: 3, Line: "ąb"}}},
+		},
+		{
+			name:    "match after multibyte characters of regexp engine",
+			pattern: `w\ną`,
+			engine:  common.QueryEngineRegexp,
+			content: []string{"żółw", "ąb"},
+			want:    map[int][]position{0: {{Index: 10, Column: 4, EndIndex: 11, EndColumn: 2, Line: "żółw\nąb"}}},
+		},
+		{
+			name:    "match in first character of line",
+			pattern: `^c`,
+			content: []string{"ab", "c"},
+			want:    map[int][]position{1: {{Index: 11, Column: 1, EndIndex: 11, EndColumn: 2, Line: "c"}}},
+		},
+		{
+			name:    "match ends with new line",
+			pattern: `b\n`,
+			content: []string{"ab", "c"},
+			want:    map[int][]position{0: {{Index: 10, Column: 2, EndIndex: 10, EndColumn: 4, Line: "ab"}}},
+		},
+		{
+			name:    "matches in many lines",
+			pattern: `ż`,
+			content: []string{"ż", "aż", "b"},
+			want: map[int][]position{
+				0: {{Index: 10, Column: 1, EndIndex: 10, EndColumn: 2, Line: "ż"}},
+				1: {{Index: 11, Column: 2, EndIndex: 11, EndColumn: 3, Line: "aż"}},
+			},
+		},
+		{
+			name:    "not found",
+			pattern: `x`,
+			content: []string{"a", "b"},
+			want:    map[int][]position{},
+		},
+	}
+
+	logger := zerolog.Nop()
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+
+			q := common.Query{Pattern: tt.pattern, Engine: tt.engine, Multiline: true}
+			rx, err := compileQuery(q, nil, true, 0)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			got := make(map[int][]position)
+			for i, matches := range findQueryMatches(tt.content, 10, rx, q, newFileTimeouts(&logger, "a.txt", 0)) {
+				for _, m := range matches {
+					got[i] = append(got[i], position{Index: m.Index, Column: m.Column, EndIndex: m.EndIndex, EndColumn: m.EndColumn, Line: m.Line})
+				}
+			}
+
+			if reflect.DeepEqual(got, tt.want) == false {
+				t.Errorf("found %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,472 @@
+package scanner
+
+import (
+	"fmt"
+	"regexp"
+	"regexp/syntax"
+	"strings"
+	"time"
+	"unicode"
+	"unicode/utf8"
+
+	common "gorex/pkg/common"
+
+	"github.com/dlclark/regexp2"
+	syntax2 "github.com/dlclark/regexp2/syntax"
+)
+
+// -----------------------------------------------------------------------------
+// types
+// -----------------------------------------------------------------------------
+
+// matcher finds pattern of query in text. Positions of matches are counted in characters (runes).
+// Error is returned when match is not finished in time (see ScanConfig.MatchTimeout).
+type matcher interface {
+	// matchString returns true when pattern is found in text
+	matchString(text string) (bool, error)
+	// find returns first match of pattern in text (nil when pattern is not found)
+	find(text string) (*matchResult, error)
+	// findAll returns every (not overlapping) match of pattern in text
+	findAll(text string) ([]matchResult, error)
+}
+
+// matchResult provides position, length (in characters) and named groups of single match
+type matchResult struct {
+	index  int
+	length int
+	groups map[string]string
+}
+
+// regexp2Matcher matches pattern with regexp2 (QueryEngineRegexp2)
+type regexp2Matcher struct {
+	rx *regexp2.Regexp
+}
+
+// regexpMatcher matches pattern with standard regexp package (QueryEngineRegexp)
+type regexpMatcher struct {
+	rx *regexp.Regexp
+}
+
+// literalMatcher finds pattern as plain substring (QueryEngineLiteral)
+type literalMatcher struct {
+	literal string
+}
+
+// ahoCorasickMatcher finds any of many literals in single pass of text (QueryEngineAhoCorasick).
+// Matches are leftmost-longest: match which starts first wins and the longest literal wins when many literals start there.
+type ahoCorasickMatcher struct {
+	nodes      []acNode
+	ignoreCase bool
+}
+
+// acNode is state of Aho-Corasick automaton. Length is length of the longest literal which ends in state
+// (found directly or by failure links), 0 when no literal ends in state. Depth is length of prefix of literal of state.
+type acNode struct {
+	next   map[rune]int
+	fail   int
+	length int
+	depth  int
+}
+
+// regexFlags maps options of query to options of regexp2
+var regexFlags = map[common.RegexOption]regexp2.RegexOptions{
+	common.RegexOptionIgnoreCase:      regexp2.IgnoreCase,
+	common.RegexOptionMultiline:       regexp2.Multiline,
+	common.RegexOptionExplicitCapture: regexp2.ExplicitCapture,
+	common.RegexOptionRE2:             regexp2.RE2,
+	common.RegexOptionRightToLeft:     regexp2.RightToLeft,
+	common.RegexOptionECMAScript:      regexp2.ECMAScript,
+}
+
+// regexpFlags maps options of query to flags of regexp (options not supported by regexp are not listed)
+var regexpFlags = map[common.RegexOption]string{
+	common.RegexOptionIgnoreCase: "i",
+	common.RegexOptionMultiline:  "m",
+}
+
+// -----------------------------------------------------------------------------
+// functions
+// -----------------------------------------------------------------------------
+
+// compileMatcher creates matcher of pattern. Multiline query is matched against joined content of scope,
+// so ^ and $ match at line boundaries and dot does not match new line (otherwise dot matches any character).
+// Options are validated with configuration, unsupported options are ignored.
+// Error of invalid pattern contains position (in characters) where problem is found.
+// Timeout (0 means no limit) is used by QueryEngineRegexp2 only, other engines match in linear time.
+func compileMatcher(pattern string, engine common.QueryEngine, options string, multiline bool, timeout time.Duration) (matcher, error) {
+
+	m, err := newMatcher(pattern, engine, options, multiline, timeout)
+	if err != nil {
+		position := errorPosition(pattern, err, func(prefix string) error {
+			_, err := newMatcher(prefix, engine, options, multiline, timeout)
+			return err
+		})
+		return nil, fmt.Errorf("%v at position %v", err, position)
+	}
+
+	return m, nil
+}
+
+// newMatcher creates matcher of pattern (see compileMatcher)
+func newMatcher(pattern string, engine common.QueryEngine, options string, multiline bool, timeout time.Duration) (matcher, error) {
+
+	parsed, _ := common.ParseRegexOptions(options)
+	ignoreCase := ignoresCase(options)
+
+	switch engine.OrDefault() {
+	case common.QueryEngineRegexp:
+		flags := "s"
+		if multiline == true {
+			flags = "m"
+		}
+		for _, o := range parsed {
+			if f, ok := regexpFlags[o]; (ok == true) && (strings.Contains(flags, f) == false) {
+				flags += f
+			}
+		}
+		rx, err := regexp.Compile("(?" + flags + ")" + pattern)
+		if err != nil {
+			if e, ok := err.(*syntax.Error); ok == true {
+				// flags are not part of pattern of query
+				e.Expr = strings.TrimPrefix(e.Expr, "(?"+flags+")")
+			}
+			return nil, err
+		}
+		return &regexpMatcher{rx: rx}, nil
+
+	case common.QueryEngineLiteral:
+		if ignoreCase == true {
+			return newAhoCorasickMatcher([]string{pattern}, true), nil
+		}
+		return &literalMatcher{literal: pattern}, nil
+
+	case common.QueryEngineAhoCorasick:
+		return newAhoCorasickMatcher(strings.Split(pattern, "|"), ignoreCase), nil
+
+	default:
+		var defaults regexp2.RegexOptions = regexOpt
+		if multiline == true {
+			defaults = regexp2.Multiline
+		}
+		rx, err := regexp2.Compile(pattern, regexOptions(defaults, options))
+		if err != nil {
+			return nil, err
+		}
+		if timeout > 0 {
+			rx.MatchTimeout = timeout
+		}
+		return &regexp2Matcher{rx: rx}, nil
+	}
+}
+
+// errorPosition returns position (1-based, in characters) of the end of the shortest prefix of pattern
+// which fails with the same error as whole pattern, e.g. 1 for missing ) of "(abc" or 3 for unexpected ) of "ab)c".
+func errorPosition(pattern string, err error, compile func(prefix string) error) int {
+
+	code := errorCode(err)
+	position := 0
+	for i := range pattern {
+		if i == 0 {
+			continue
+		}
+		position++
+		if e := compile(pattern[:i]); (e != nil) && (errorCode(e) == code) {
+			return position
+		}
+	}
+
+	return utf8.RuneCountInString(pattern)
+}
+
+// errorCode returns kind of compile error (without arguments and pattern)
+func errorCode(err error) string {
+	switch e := err.(type) {
+	case *syntax2.Error:
+		return string(e.Code)
+	case *syntax.Error:
+		return string(e.Code)
+	default:
+		return err.Error()
+	}
+}
+
+// regexOptions returns default options extended with comma separated options of query.
+// Unknown options are ignored (they are reported by validation of configuration).
+func regexOptions(defaults regexp2.RegexOptions, options string) regexp2.RegexOptions {
+	parsed, _ := common.ParseRegexOptions(options)
+	for _, o := range parsed {
+		defaults |= regexFlags[o]
+	}
+	return defaults
+}
+
+// ignoresCase returns true when comma separated options of query contain ignoreCase
+func ignoresCase(options string) bool {
+	parsed, _ := common.ParseRegexOptions(options)
+	for _, o := range parsed {
+		if o == common.RegexOptionIgnoreCase {
+			return true
+		}
+	}
+	return false
+}
+
+// escapeForEngine returns value which is matched literally by pattern of engine.
+// Literal engines match value as it is (value used by QueryEngineAhoCorasick is placed in single literal,
+// see compileExpanded).
+func escapeForEngine(engine common.QueryEngine, value string) string {
+	switch engine.OrDefault() {
+	case common.QueryEngineRegexp:
+		return regexp.QuoteMeta(value)
+	case common.QueryEngineLiteral, common.QueryEngineAhoCorasick:
+		return value
+	default:
+		return regexp2.Escape(value)
+	}
+}
+
+func newAhoCorasickMatcher(literals []string, ignoreCase bool) *ahoCorasickMatcher {
+
+	m := &ahoCorasickMatcher{
+		nodes:      []acNode{{next: make(map[rune]int)}},
+		ignoreCase: ignoreCase,
+	}
+
+	// trie of literals
+	for _, l := range literals {
+		if l == "" {
+			continue
+		}
+		state := 0
+		length := 0
+		for _, r := range l {
+			r = m.fold(r)
+			n, ok := m.nodes[state].next[r]
+			if ok == false {
+				m.nodes = append(m.nodes, acNode{next: make(map[rune]int), depth: m.nodes[state].depth + 1})
+				n = len(m.nodes) - 1
+				m.nodes[state].next[r] = n
+			}
+			state = n
+			length++
+		}
+		m.nodes[state].length = length
+	}
+
+	// failure links (breadth first, so failure state is always processed before state)
+	queue := []int{}
+	for _, n := range m.nodes[0].next {
+		queue = append(queue, n)
+	}
+	for len(queue) > 0 {
+		state := queue[0]
+		queue = queue[1:]
+
+		for r, n := range m.nodes[state].next {
+			f := m.nodes[state].fail
+			for {
+				if t, ok := m.nodes[f].next[r]; ok == true {
+					m.nodes[n].fail = t
+					break
+				}
+				if f == 0 {
+					break
+				}
+				f = m.nodes[f].fail
+			}
+			if m.nodes[n].length == 0 {
+				m.nodes[n].length = m.nodes[m.nodes[n].fail].length
+			}
+			queue = append(queue, n)
+		}
+	}
+
+	return m
+}
+
+// -----------------------------------------------------------------------------
+// extensions
+// -----------------------------------------------------------------------------
+
+func (m *regexp2Matcher) matchString(text string) (bool, error) {
+	ok, err := m.rx.MatchString(text)
+	return (ok == true) && (err == nil), err
+}
+
+func (m *regexp2Matcher) find(text string) (*matchResult, error) {
+	match, err := m.rx.FindStringMatch(text)
+	if (err != nil) || (match == nil) {
+		return nil, err
+	}
+	return &matchResult{index: match.Index, length: match.Length, groups: namedGroups(m.rx, match)}, nil
+}
+
+func (m *regexp2Matcher) findAll(text string) ([]matchResult, error) {
+	var result []matchResult
+	match, err := m.rx.FindStringMatch(text)
+	for (err == nil) && (match != nil) {
+		result = append(result, matchResult{index: match.Index, length: match.Length, groups: namedGroups(m.rx, match)})
+		match, err = m.rx.FindNextMatch(match)
+	}
+	return result, err
+}
+
+func (m *regexpMatcher) matchString(text string) (bool, error) {
+	return m.rx.MatchString(text), nil
+}
+
+func (m *regexpMatcher) find(text string) (*matchResult, error) {
+	loc := m.rx.FindStringSubmatchIndex(text)
+	if loc == nil {
+		return nil, nil
+	}
+	r := m.result(text, loc)
+	return &r, nil
+}
+
+func (m *regexpMatcher) findAll(text string) ([]matchResult, error) {
+	var result []matchResult
+	for _, loc := range m.rx.FindAllStringSubmatchIndex(text, -1) {
+		result = append(result, m.result(text, loc))
+	}
+	return result, nil
+}
+
+// result converts byte positions of submatches into match (positions in characters and named groups)
+func (m *regexpMatcher) result(text string, loc []int) matchResult {
+
+	r := matchResult{
+		index:  utf8.RuneCountInString(text[:loc[0]]),
+		length: utf8.RuneCountInString(text[loc[0]:loc[1]]),
+	}
+
+	for i, name := range m.rx.SubexpNames() {
+		if (name == "") || (loc[2*i] < 0) {
+			continue
+		}
+		if r.groups == nil {
+			r.groups = make(map[string]string)
+		}
+		r.groups[name] = text[loc[2*i]:loc[2*i+1]]
+	}
+
+	return r
+}
+
+func (m *literalMatcher) matchString(text string) (bool, error) {
+	return strings.Contains(text, m.literal), nil
+}
+
+func (m *literalMatcher) find(text string) (*matchResult, error) {
+	i := strings.Index(text, m.literal)
+	if i < 0 {
+		return nil, nil
+	}
+	return &matchResult{
+		index:  utf8.RuneCountInString(text[:i]),
+		length: utf8.RuneCountInString(m.literal),
+	}, nil
+}
+
+func (m *literalMatcher) findAll(text string) ([]matchResult, error) {
+
+	var result []matchResult
+	length := utf8.RuneCountInString(m.literal)
+
+	// position of start in bytes and in characters
+	start, index := 0, 0
+	for start <= len(text) {
+		i := strings.Index(text[start:], m.literal)
+		if i < 0 {
+			break
+		}
+		index += utf8.RuneCountInString(text[start : start+i])
+		start += i
+
+		result = append(result, matchResult{index: index, length: length})
+
+		skip := len(m.literal)
+		if skip == 0 {
+			// empty literal is found at every position
+			if start == len(text) {
+				break
+			}
+			_, skip = utf8.DecodeRuneInString(text[start:])
+		}
+		index += utf8.RuneCountInString(text[start : start+skip])
+		start += skip
+	}
+
+	return result, nil
+}
+
+func (m *ahoCorasickMatcher) fold(r rune) rune {
+	if m.ignoreCase == true {
+		return unicode.ToLower(r)
+	}
+	return r
+}
+
+// scan walks automaton over text and calls found for every match (scan is stopped when found returns false).
+// Match is reported when no literal which starts before it (or at the same position) can end later, then automaton
+// is restarted after the match, so matches do not overlap.
+func (m *ahoCorasickMatcher) scan(text string, found func(r matchResult) bool) {
+
+	runes := []rune(text)
+
+	var best *matchResult
+	state := 0
+	for i := 0; i < len(runes); i++ {
+		r := m.fold(runes[i])
+		for {
+			if n, ok := m.nodes[state].next[r]; ok == true {
+				state = n
+				break
+			}
+			if state == 0 {
+				break
+			}
+			state = m.nodes[state].fail
+		}
+
+		// the longest literal which ends here starts first
+		if length := m.nodes[state].length; length > 0 {
+			if start := i - length + 1; (best == nil) || (start <= best.index) {
+				best = &matchResult{index: start, length: length}
+			}
+		}
+
+		// literal found later starts after i - depth (and nothing is found after the end of text)
+		if (best != nil) && ((i-m.nodes[state].depth+1 > best.index) || (i == len(runes)-1)) {
+			if found(*best) == false {
+				return
+			}
+			i = best.index + best.length - 1
+			best = nil
+			state = 0
+		}
+	}
+}
+
+func (m *ahoCorasickMatcher) matchString(text string) (bool, error) {
+	r, err := m.find(text)
+	return r != nil, err
+}
+
+func (m *ahoCorasickMatcher) find(text string) (*matchResult, error) {
+	var result *matchResult
+	m.scan(text, func(r matchResult) bool {
+		result = &r
+		return false
+	})
+	return result, nil
+}
+
+func (m *ahoCorasickMatcher) findAll(text string) ([]matchResult, error) {
+	var result []matchResult
+	m.scan(text, func(r matchResult) bool {
+		result = append(result, r)
+		return true
+	})
+	return result, nil
+}
@@ -0,0 +1,391 @@
+package scanner
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	common "gorex/pkg/common"
+)
+
+// -----------------------------------------------------------------------------
+// functions
+// -----------------------------------------------------------------------------
+
+// exampleLines returns lines of every file of example folder
+func exampleLines(tb testing.TB) []string {
+	tb.Helper()
+
+	var result []string
+	for _, content := range exampleFiles(tb) {
+		result = append(result, strings.Split(content, "\n")...)
+	}
+	return result
+}
+
+// benchmarkMatcher finds every match of pattern in lines of example files
+func benchmarkMatcher(b *testing.B, pattern string, engine common.QueryEngine) {
+
+	lines := exampleLines(b)
+
+	m, err := compileMatcher(pattern, engine, "", false, 0)
+	if err != nil {
+		b.Fatal(err)
+	}
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		for _, line := range lines {
+			if _, err := m.findAll(line); err != nil {
+				b.Fatal(err)
+			}
+		}
+	}
+}
+
+// BenchmarkMatcher_regexp2 finds matches in example files with regexp2 (default engine)
+func BenchmarkMatcher_regexp2(b *testing.B) {
+	benchmarkMatcher(b, `COMMAND=A|COMMAND=X|BEGIN`, common.QueryEngineRegexp2)
+}
+
+// BenchmarkMatcher_regexp finds matches in example files with standard regexp package
+func BenchmarkMatcher_regexp(b *testing.B) {
+	benchmarkMatcher(b, `COMMAND=A|COMMAND=X|BEGIN`, common.QueryEngineRegexp)
+}
+
+// BenchmarkMatcher_literal finds matches in example files with single literal (strings.Index)
+func BenchmarkMatcher_literal(b *testing.B) {
+	benchmarkMatcher(b, `COMMAND=`, common.QueryEngineLiteral)
+}
+
+// BenchmarkMatcher_ahoCorasick finds matches in example files with Aho-Corasick automaton
+func BenchmarkMatcher_ahoCorasick(b *testing.B) {
+	benchmarkMatcher(b, `COMMAND=A|COMMAND=X|BEGIN`, common.QueryEngineAhoCorasick)
+}
+
+// TestAhoCorasickMatcher checks matches of automaton (positions and lengths in characters)
+func TestAhoCorasickMatcher(t *testing.T) {
+
+	tests := []struct {
+		name       string
+		literals   []string
+		ignoreCase bool
+		text       string
+		want       []matchResult
+	}{
+		{
+			name:     "many literals",
+			literals: []string{"BEGIN", "END", "COMMIT"},
+			text:     "BEGIN; COMMIT; END",
+			want:     []matchResult{{index: 0, length: 5}, {index: 7, length: 6}, {index: 15, length: 3}},
+		},
+		{
+			name:     "literal found by failure link",
+			literals: []string{"he", "she", "his", "hers"},
+			text:     "ushers",
+			want:     []matchResult{{index: 1, length: 3}},
+		},
+		{
+			name:     "longest literal wins",
+			literals: []string{"b", "ab"},
+			text:     "ab b",
+			want:     []matchResult{{index: 0, length: 2}, {index: 3, length: 1}},
+		},
+		{
+			name:     "matches do not overlap",
+			literals: []string{"ab", "bc"},
+			text:     "abc",
+			want:     []matchResult{{index: 0, length: 2}},
+		},
+		{
+			name:     "leftmost literal wins over literal which ends first",
+			literals: []string{"abcd", "bc"},
+			text:     "abcd bc",
+			want:     []matchResult{{index: 0, length: 4}, {index: 5, length: 2}},
+		},
+		{
+			name:     "longest of literals which start at the same position",
+			literals: []string{"a", "abc", "ab"},
+			text:     "abcab",
+			want:     []matchResult{{index: 0, length: 3}, {index: 3, length: 2}},
+		},
+		{
+			name:     "scan is restarted after match",
+			literals: []string{"abx", "bc", "c"},
+			text:     "abcc",
+			want:     []matchResult{{index: 1, length: 2}, {index: 3, length: 1}},
+		},
+		{
+			name:     "scan is restarted after match at the end of text",
+			literals: []string{"a", "aaaa"},
+			text:     "baaa",
+			want:     []matchResult{{index: 1, length: 1}, {index: 2, length: 1}, {index: 3, length: 1}},
+		},
+		{
+			name:       "ignore case",
+			literals:   []string{"Commit"},
+			ignoreCase: true,
+			text:       "COMMIT; commit",
+			want:       []matchResult{{index: 0, length: 6}, {index: 8, length: 6}},
+		},
+		{
+			name:     "positions in characters",
+			literals: []string{"żółw", "ć"},
+			text:     "zażółć żółw",
+			want:     []matchResult{{index: 5, length: 1}, {index: 7, length: 4}},
+		},
+		{
+			name:     "empty literal is ignored",
+			literals: []string{"", "x"},
+			text:     "axb",
+			want:     []matchResult{{index: 1, length: 1}},
+		},
+		{
+			name:     "not found",
+			literals: []string{"x"},
+			text:     "abc",
+			want:     nil,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+
+			m := newAhoCorasickMatcher(tt.literals, tt.ignoreCase)
+
+			got, _ := m.findAll(tt.text)
+			if reflect.DeepEqual(got, tt.want) == false {
+				t.Errorf("findAll = %+v, want %+v", got, tt.want)
+			}
+
+			first, _ := m.find(tt.text)
+			if (first == nil) != (len(tt.want) == 0) || ((first != nil) && (reflect.DeepEqual(*first, tt.want[0]) == false)) {
+				t.Errorf("find = %+v, want first of %+v", first, tt.want)
+			}
+		})
+	}
+}
+
+// TestRegexpMatcher_Result checks that byte positions of regexp are converted into characters
+func TestRegexpMatcher_Result(t *testing.T) {
+
+	m, err := compileMatcher(`(?P<v>ł+)`, common.QueryEngineRegexp, "", false, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	got, _ := m.findAll("zażółć łł")
+	want := []matchResult{
+		{index: 4, length: 1, groups: map[string]string{"v": "ł"}},
+		{index: 7, length: 2, groups: map[string]string{"v": "łł"}},
+	}
+	if reflect.DeepEqual(got, want) == false {
+		t.Errorf("findAll = %+v, want %+v", got, want)
+	}
+
+	first, _ := m.find("ąę ł")
+	if (first == nil) || (first.index != 3) || (first.length != 1) {
+		t.Errorf("find = %+v, want index [3] and length [1]", first)
+	}
+}
+
+// TestLiteralMatcher_FindAll checks positions (in characters) of every match of literal
+func TestLiteralMatcher_FindAll(t *testing.T) {
+
+	tests := []struct {
+		literal string
+		text    string
+		want    []matchResult
+	}{
+		{literal: "ó", text: "óaóó", want: []matchResult{{index: 0, length: 1}, {index: 2, length: 1}, {index: 3, length: 1}}},
+		{literal: "żó", text: "ż żóż żó", want: []matchResult{{index: 2, length: 2}, {index: 6, length: 2}}},
+		{literal: "aa", text: "aaa", want: []matchResult{{index: 0, length: 2}}},
+		{literal: "", text: "ąb", want: []matchResult{{index: 0}, {index: 1}, {index: 2}}},
+		{literal: "x", text: "ąb", want: nil},
+	}
+
+	for _, tt := range tests {
+		m := &literalMatcher{literal: tt.literal}
+		got, _ := m.findAll(tt.text)
+		if reflect.DeepEqual(got, tt.want) == false {
+			t.Errorf("findAll(%q) of [%v] = %+v, want %+v", tt.text, tt.literal, got, tt.want)
+		}
+	}
+}
+
+// TestCompileExpanded_AhoCorasick checks that captured value is part of single literal even when it contains separator
+func TestCompileExpanded_AhoCorasick(t *testing.T) {
+
+	m, err := compileExpanded(`x=${op}|y`, map[string]string{"op": "a|b"}, common.QueryEngineAhoCorasick, "ignoreCase", false, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for text, want := range map[string]bool{"X=A|B": true, "y": true, "x=a": false, "b": false} {
+		if got, _ := m.matchString(text); got != want {
+			t.Errorf("matchString(%q) = %v, want %v", text, got, want)
+		}
+	}
+}
+
+// TestCompileScope_Error checks that error of invalid pattern names scope, query and position (in characters) of problem
+func TestCompileScope_Error(t *testing.T) {
+
+	valid := common.ScopeConfig{Name: "s", StartQuery: `^BEGIN$`, FinishQuery: `^END$`}
+
+	tests := []struct {
+		name  string
+		scope func(sc *common.ScopeConfig)
+		want  string
+	}{
+		{
+			name:  "search query of regexp2 engine",
+			scope: func(sc *common.ScopeConfig) { sc.SearchQuery = []common.Query{{Pattern: `x`}, {Pattern: `(abc`}} },
+			want:  "Invalid #1 search query of scope [2]: error parsing regexp: missing closing ) in `(abc` at position 1",
+		},
+		{
+			name: "search query of regexp engine",
+			scope: func(sc *common.ScopeConfig) {
+				sc.SearchQuery = []common.Query{{Pattern: `x`}, {Pattern: `(abc`, Engine: common.QueryEngineRegexp}}
+			},
+			want: "Invalid #1 search query of scope [2]: error parsing regexp: missing closing ): `(abc` at position 1",
+		},
+		{
+			name:  "start query after multibyte characters",
+			scope: func(sc *common.ScopeConfig) { sc.StartQuery = `żó[a` },
+			want:  "Invalid start query of scope [2]: error parsing regexp: unterminated [] set in `żó[a` at position 3",
+		},
+		{
+			name:  "finish query",
+			scope: func(sc *common.ScopeConfig) { sc.FinishQuery = `ab)c` },
+			want:  "Invalid finish query of scope [2]: error parsing regexp: unexpected ) in `ab)c` at position 3",
+		},
+		{
+			name: "exclude query with options",
+			scope: func(sc *common.ScopeConfig) {
+				sc.ExcludeQuery = []common.Query{{Pattern: `a**`, Engine: common.QueryEngineRegexp, Options: "ignoreCase"}}
+			},
+			want: "Invalid #0 exclude query of scope [2]: error parsing regexp: invalid nested repetition operator: `**` at position 3",
+		},
+		{
+			name: "query of child scope",
+			scope: func(sc *common.ScopeConfig) {
+				child := valid
+				child.SearchQuery = []common.Query{{Pattern: `a{2,1}`}}
+				sc.Scopes = []common.ScopeConfig{valid, child}
+			},
+			want: "Invalid #0 search query of scope [2.1]: error parsing regexp: invalid repeat count in `a{2,1}` at position 6",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+
+			sc := valid
+			tt.scope(&sc)
+
+			_, err := compileScope(sc, "2", 0)
+			if (err == nil) || (err.Error() != tt.want) {
+				t.Errorf("error [%v], want [%v]", err, tt.want)
+			}
+		})
+	}
+}
+
+// TestCompileMatcher_Options checks that options of query are applied by every engine
+func TestCompileMatcher_Options(t *testing.T) {
+
+	tests := []struct {
+		name    string
+		pattern string
+		engine  common.QueryEngine
+		options string
+		text    string
+		want    bool
+	}{
+		{name: "case of regexp2", pattern: `commit`, engine: common.QueryEngineRegexp2, text: "COMMIT", want: false},
+		{name: "ignoreCase of regexp2", pattern: `commit`, engine: common.QueryEngineRegexp2, options: "ignoreCase", text: "COMMIT", want: true},
+		{name: "case of regexp", pattern: `commit`, engine: common.QueryEngineRegexp, text: "COMMIT", want: false},
+		{name: "ignoreCase of regexp", pattern: `commit`, engine: common.QueryEngineRegexp, options: "ignoreCase", text: "COMMIT", want: true},
+		{name: "case of literal", pattern: `commit`, engine: common.QueryEngineLiteral, text: "COMMIT", want: false},
+		{name: "ignoreCase of literal", pattern: `commit`, engine: common.QueryEngineLiteral, options: "ignoreCase", text: "COMMIT", want: true},
+		{name: "case of ahoCorasick", pattern: `commit|end`, engine: common.QueryEngineAhoCorasick, text: "END", want: false},
+		{name: "ignoreCase of ahoCorasick", pattern: `commit|end`, engine: common.QueryEngineAhoCorasick, options: "ignoreCase", text: "END", want: true},
+		{name: "option names are case insensitive", pattern: `commit`, engine: common.QueryEngineRegexp, options: " IgnoreCase ", text: "COMMIT", want: true},
+		{name: "ecmaScript of regexp2", pattern: `^\w+$`, engine: common.QueryEngineRegexp2, options: "ecmaScript", text: "żółw", want: false},
+		{name: "re2 of regexp2", pattern: `^(?P<x>a)$`, engine: common.QueryEngineRegexp2, options: "re2", text: "a", want: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m, err := compileMatcher(tt.pattern, tt.engine, tt.options, false, 0)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got, _ := m.matchString(tt.text); got != tt.want {
+				t.Errorf("matchString(%q) = %v, want %v", tt.text, got, tt.want)
+			}
+		})
+	}
+
+	// groups of regexp2 are captured or not depending on explicitCapture, named groups are always captured
+	for options, want := range map[string]int{"": 2, "explicitCapture": 1} {
+		m, err := compileMatcher(`(a)(?<n>b)`, common.QueryEngineRegexp2, options, false, 0)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := len(m.(*regexp2Matcher).rx.GetGroupNumbers()) - 1; got != want {
+			t.Errorf("[%v] groups with options %q, want [%v]", got, options, want)
+		}
+	}
+
+	// rightToLeft finds last match of regexp2 first
+	m, err := compileMatcher(`a\d`, common.QueryEngineRegexp2, "rightToLeft", false, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if first, _ := m.find("a1 a2"); (first == nil) || (first.index != 3) {
+		t.Errorf("find with rightToLeft = %+v, want index [3]", first)
+	}
+}
+
+// TestCompileScope_Timeout checks that own timeout of query overrides timeout of scan
+func TestCompileScope_Timeout(t *testing.T) {
+
+	sc := common.ScopeConfig{
+		Name:               "s",
+		StartQuery:         `^BEGIN$`,
+		FinishQuery:        `^END$`,
+		StartQueryTimeout:  10,
+		FinishQueryTimeout: 20,
+		SearchQuery:        []common.Query{{Pattern: `a`}, {Pattern: `b`, MatchTimeout: 30}},
+		ExcludeQuery:       []common.Query{{Pattern: `c`, MatchTimeout: 40}, {Pattern: `d`}},
+	}
+
+	compiled, err := compileScope(sc, "0", 5*time.Millisecond)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name string
+		m    matcher
+		want time.Duration
+	}{
+		{name: "start query", m: compiled.rxStart, want: 10 * time.Millisecond},
+		{name: "finish query", m: compiled.rxStop, want: 20 * time.Millisecond},
+		{name: "search query without timeout", m: compiled.search[0], want: 5 * time.Millisecond},
+		{name: "search query", m: compiled.search[1], want: 30 * time.Millisecond},
+		{name: "exclude query", m: compiled.exclude[0], want: 40 * time.Millisecond},
+		{name: "exclude query without timeout", m: compiled.exclude[1], want: 5 * time.Millisecond},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.m.(*regexp2Matcher).rx.MatchTimeout; got != tt.want {
+				t.Errorf("timeout [%v], want [%v]", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,324 @@
+// Package scanner provides engine which finds scopes in files and search queries inside them.
+package scanner
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"sync"
+	"time"
+
+	common "gorex/pkg/common"
+
+	"github.com/rs/zerolog"
+)
+
+// -----------------------------------------------------------------------------
+// types
+// -----------------------------------------------------------------------------
+
+// Scanner provides scan of folder described by ScanConfig.
+// Scanner does not keep any state between scans, so it can be used many times.
+type Scanner struct {
+	config common.ScanConfig
+	scopes []*compiledScope
+	logger zerolog.Logger
+}
+
+// New validates configuration and compiles its queries. Error of invalid pattern names scope, query and position.
+func New(config common.ScanConfig, logger zerolog.Logger) (*Scanner, error) {
+	if err := config.IsValid(); err != nil {
+		return nil, err
+	}
+
+	var scopes []*compiledScope
+	for i, sc := range config.Scopes {
+		compiled, err := compileScope(sc, fmt.Sprint(i), time.Duration(config.MatchTimeout)*time.Millisecond)
+		if err != nil {
+			return nil, err
+		}
+		scopes = append(scopes, compiled)
+	}
+
+	return &Scanner{
+		config: config,
+		scopes: scopes,
+		logger: logger,
+	}, nil
+}
+
+// -----------------------------------------------------------------------------
+// functions
+// -----------------------------------------------------------------------------
+
+// Workers returns number of goroutines which process files.
+// When configuration does not define workers then number of CPUs is used.
+func (s *Scanner) Workers() int {
+	if s.config.Workers > 0 {
+		return s.config.Workers
+	}
+	return runtime.NumCPU()
+}
+
+// CaptureNames returns sorted names of named groups of start and search queries of every scope
+// (values of the groups are captured in found scopes and match lines)
+func (s *Scanner) CaptureNames() []string {
+
+	set := make(map[string]bool)
+	var add func(scopes []*compiledScope)
+	add = func(scopes []*compiledScope) {
+		for _, sc := range scopes {
+			for _, name := range sc.groups {
+				set[name] = true
+			}
+			add(sc.children)
+		}
+	}
+	add(s.scopes)
+
+	var result []string
+	for name := range set {
+		result = append(result, name)
+	}
+	sort.Strings(result)
+	return result
+}
+
+// Scan walks configured folder and returns summary of scopes found in matched files
+func (s *Scanner) Scan() (common.ScanSummary, error) {
+	return s.ScanContext(context.Background())
+}
+
+// ScanContext is Scan which stops when context is done (cancelled or deadline exceeded).
+// Then summary contains scopes found so far, it is flagged as incomplete and error of context is returned.
+func (s *Scanner) ScanContext(ctx context.Context) (common.ScanSummary, error) {
+	return s.run(ctx, nil, true)
+}
+
+// Stream walks configured folder and reports found scopes and matches with events only.
+// Scopes are not collected, so returned summary contains counters only
+// (Summary is empty and FileScopeSummary passed to OnFileDone has no Scopes).
+// Stream returns when every file is done, no callback is executed after it returns.
+func (s *Scanner) Stream(events Events) (common.ScanSummary, error) {
+	return s.StreamContext(context.Background(), events)
+}
+
+// StreamContext is Stream which stops when context is done (see ScanContext)
+func (s *Scanner) StreamContext(ctx context.Context, events Events) (common.ScanSummary, error) {
+	return s.run(ctx, &events, false)
+}
+
+// run executes scan. When collect is true every scope with matches is kept in summary
+// (and names of files without scopes are listed).
+func (s *Scanner) run(ctx context.Context, events *Events, collect bool) (common.ScanSummary, error) {
+
+	logger := s.logger
+
+	var folder string = s.config.Folder
+	var filter string = s.config.Filter
+
+	abs, err := filepath.Abs(folder)
+	if err == nil {
+		folder = abs
+		logger.Trace().Msgf("Folder resolved to: %v", folder)
+	} else {
+		logger.Err(err).Send()
+	}
+
+	var scanSummary common.ScanSummary = common.ScanSummary{
+		Folder:       folder,
+		Filter:       filter,
+		CreationTime: time.Now(),
+		Summary:      nil,
+		ScanFiles:    0,
+	}
+
+	workers := s.Workers()
+	logger.Trace().Msgf("Workers: %v", workers)
+
+	var wgFile sync.WaitGroup
+	var fileErr error
+	cFile := make(chan string, workers)
+	mutex := &sync.Mutex{}
+
+	events = events.synchronized()
+
+	// -----------------------------------------------------------------------------
+	// read files and find scope(s)
+	// -----------------------------------------------------------------------------
+	for w := 0; w < workers; w++ {
+		go func() {
+			for path := range cFile {
+
+				if ctx.Err() != nil {
+					// files waiting in channel are skipped after cancel
+					wgFile.Done()
+					continue
+				}
+
+				logger.Info().Msgf("\t-> Process file [%v]", path)
+
+				fileScopeSummary, err := s.scanFile(ctx, path, events, collect)
+
+				mutex.Lock()
+				if err != nil {
+					logger.Err(err).Send()
+					if fileErr == nil {
+						fileErr = err
+					}
+				}
+				scanSummary.ScanFiles++
+				if collect && (((fileScopeSummary.Scopes != nil) && (len(fileScopeSummary.Scopes) > 0)) || (len(fileScopeSummary.TimedOutLines) > 0) || (fileScopeSummary.Aborted == true)) {
+					logger.Trace().Msgf("ADD FILE [%v] MATCHES TO SUMMARY", fileScopeSummary.FileName)
+					scanSummary.Summary = append(scanSummary.Summary, fileScopeSummary)
+				} else if collect {
+					scanSummary.FilesWithoutScopes = append(scanSummary.FilesWithoutScopes, fileScopeSummary.FileName)
+				}
+				mutex.Unlock()
+
+				wgFile.Done()
+			}
+		}()
+	}
+
+	// -----------------------------------------------------------------------------
+
+	logger.Info().Msgf("SCAN FOLDER [%v]...", folder)
+
+	err = filepath.Walk(folder, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+
+		if info.IsDir() == true {
+			return nil
+		}
+
+		matched, merr := filepath.Match(filter, filepath.Base(path))
+		if merr != nil {
+			logger.Trace().Msgf("Filter match warning:%v", merr)
+		} else {
+
+			if matched == true {
+				wgFile.Add(1)
+				select {
+				case cFile <- path:
+				case <-ctx.Done():
+					wgFile.Done()
+					return ctx.Err()
+				}
+			}
+		}
+
+		return nil
+	})
+
+	wgFile.Wait()
+	close(cFile)
+
+	// files are processed in parallel, so order of summaries depends on workers
+	sort.Slice(scanSummary.Summary, func(i, j int) bool {
+		return scanSummary.Summary[i].FileName < scanSummary.Summary[j].FileName
+	})
+	sort.Strings(scanSummary.FilesWithoutScopes)
+
+	if ctx.Err() != nil {
+		logger.Warn().Msgf("Scan stopped: %v", ctx.Err())
+		scanSummary.Incomplete = true
+		err = ctx.Err()
+	}
+
+	if err == nil {
+		err = fileErr
+	}
+
+	return scanSummary, err
+}
+
+// scanFile reads file once and passes every line to machines of all configured scopes.
+// File is aborted when context is done.
+func (s *Scanner) scanFile(ctx context.Context, path string, events *Events, collect bool) (common.FileScopeSummary, error) {
+
+	logger := s.logger
+
+	fileScopeSummary := common.FileScopeSummary{
+		FileName:   path,
+		Scopes:     []common.ScopeSummary{},
+		AllMatches: 0,
+	}
+
+	if collect == false {
+		fileScopeSummary.Scopes = nil
+	}
+
+	file, err := os.Open(path)
+	if err != nil {
+		return fileScopeSummary, err
+	}
+	defer file.Close()
+
+	events.fileStart(path)
+
+	timeouts := newFileTimeouts(&logger, path, s.config.MaxTimeouts)
+
+	machines := make([]*scopeMachine, len(s.scopes))
+	for i, sc := range s.scopes {
+		machines[i] = newScopeMachine(sc, &logger, events, collect, path, 0, timeouts)
+	}
+
+	done := ctx.Done()
+
+	scanner := bufio.NewScanner(file)
+	index := 0
+	var scan bool = true
+	var line string
+	for scan {
+		scan = scanner.Scan()
+
+		if scan == true {
+			line = scanner.Text()
+			index++
+		}
+
+		timeouts.line = index
+		for _, m := range machines {
+			m.feed(line, index, scan)
+		}
+
+		if timeouts.aborted == true {
+			// scopes which are still open are dropped
+			fileScopeSummary.Aborted = true
+			break
+		}
+
+		select {
+		case <-done:
+			logger.Warn().Msgf("File [%v] aborted in line [%v]: %v", path, index, ctx.Err())
+			fileScopeSummary.Aborted = true
+			scan = false
+		default:
+		}
+	}
+
+	if err := scanner.Err(); err != nil {
+		return fileScopeSummary, fmt.Errorf("Read file [%v] failed: %v", path, err)
+	}
+
+	// scopes are reported in order of configuration
+	for _, m := range machines {
+		m.collectResults(&fileScopeSummary)
+	}
+	fileScopeSummary.TimedOutLines = timeouts.sortedLines()
+
+	events.fileDone(fileScopeSummary)
+
+	return fileScopeSummary, nil
+}
@@ -0,0 +1,318 @@
+package scanner
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"runtime"
+	"strings"
+	"testing"
+
+	common "gorex/pkg/common"
+
+	"github.com/rs/zerolog"
+)
+
+// -----------------------------------------------------------------------------
+// functions
+// -----------------------------------------------------------------------------
+
+// writeFiles writes files (name -> content) into folder
+func writeFiles(tb testing.TB, folder string, files map[string]string) {
+	tb.Helper()
+	for name, content := range files {
+		if err := ioutil.WriteFile(filepath.Join(folder, name), []byte(content), 0644); err != nil {
+			tb.Fatal(err)
+		}
+	}
+}
+
+// scanFiles scans files (name -> content) written into temporary folder with scopes of configuration
+func scanFiles(tb testing.TB, config common.ScanConfig, files map[string]string) common.ScanSummary {
+	tb.Helper()
+
+	folder, err := ioutil.TempDir("", "gorex")
+	if err != nil {
+		tb.Fatal(err)
+	}
+	tb.Cleanup(func() { os.RemoveAll(folder) })
+
+	writeFiles(tb, folder, files)
+
+	config.Folder = folder
+	if config.Filter == "" {
+		config.Filter = "*.txt"
+	}
+
+	s, err := New(config, zerolog.Nop())
+	if err != nil {
+		tb.Fatal(err)
+	}
+
+	summary, err := s.Scan()
+	if err != nil {
+		tb.Fatal(err)
+	}
+	return summary
+}
+
+// benchmarkTree returns files of example tree: every file contains scopes (with and without commands) and text between them
+func benchmarkTree(files int, scopes int) map[string]string {
+	result := make(map[string]string)
+	for f := 0; f < files; f++ {
+		var b strings.Builder
+		for s := 0; s < scopes; s++ {
+			fmt.Fprintf(&b, "// comment %v of file %v\n", s, f)
+			b.WriteString("BEGIN\n")
+			for c := 0; c < 5; c++ {
+				if (s+c)%3 == 0 {
+					fmt.Fprintf(&b, "    COMMAND=%v\n", c)
+				} else {
+					fmt.Fprintf(&b, "    // this is a comment inside scope %v\n", c)
+				}
+			}
+			b.WriteString("END\n")
+		}
+		result[fmt.Sprintf("f%04d.txt", f)] = b.String()
+	}
+	return result
+}
+
+// BenchmarkScan compares scan of example tree by single worker and by worker per CPU (at least two workers)
+func BenchmarkScan(b *testing.B) {
+
+	folder, err := ioutil.TempDir("", "gorex")
+	if err != nil {
+		b.Fatal(err)
+	}
+	defer os.RemoveAll(folder)
+
+	writeFiles(b, folder, benchmarkTree(100, 100))
+
+	config := common.ScanConfig{
+		Folder: folder,
+		Filter: "*.txt",
+		Scopes: []common.ScopeConfig{
+			{
+				Name:                 "example-1",
+				StartQuery:           `^\W*BEGIN$`,
+				FinishQuery:          `^\W*END$`,
+				StartQueryCloseScope: true,
+				SearchQuery:          []common.Query{{Pattern: `^\s*COMMAND\=.*$`}},
+				SearchQueryMode:      common.SearchQueryOperatorAny,
+			},
+		},
+	}
+
+	n := runtime.NumCPU()
+	if n < 2 {
+		n = 2
+	}
+
+	for _, workers := range []int{1, n} {
+		config.Workers = workers
+		s, err := New(config, zerolog.Nop())
+		if err != nil {
+			b.Fatal(err)
+		}
+
+		b.Run(fmt.Sprintf("workers=%v", workers), func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				if _, err := s.Scan(); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
+
+// TestScan_FilesWithoutScopes checks that names of files without scopes are listed in summary
+func TestScan_FilesWithoutScopes(t *testing.T) {
+
+	config := common.ScanConfig{Scopes: []common.ScopeConfig{{
+		Name: "s", StartQuery: `^BEGIN$`, FinishQuery: `^END$`, SearchQuery: []common.Query{{Pattern: `x`}},
+	}}}
+
+	summary := scanFiles(t, config, map[string]string{
+		"a.txt": "BEGIN\nx\nEND\n",
+		"b.txt": "BEGIN\ny\nEND\n",
+		"c.txt": "x\n",
+	})
+
+	var got []string
+	for _, f := range summary.FilesWithoutScopes {
+		got = append(got, filepath.Base(f))
+	}
+
+	if want := []string{"b.txt", "c.txt"}; strings.Join(got, ",") != strings.Join(want, ",") {
+		t.Errorf("files without scopes %v, want %v", got, want)
+	}
+	if (len(summary.Summary) != 1) || (summary.ScanFiles != 3) {
+		t.Errorf("[%v] files with scopes of [%v] scan files, want [1] of [3]", len(summary.Summary), summary.ScanFiles)
+	}
+}
+
+// TestScanContext_Cancel checks that scan cancelled in the middle of first file returns scopes found so far
+// in summary flagged as incomplete (remaining files are not read)
+func TestScanContext_Cancel(t *testing.T) {
+
+	folder, err := ioutil.TempDir("", "gorex")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(folder)
+
+	content := "BEGIN\nx\nEND\nBEGIN\nx\nEND\n"
+	writeFiles(t, folder, map[string]string{"a.txt": content, "b.txt": content})
+
+	s, err := New(common.ScanConfig{
+		Folder:  folder,
+		Filter:  "*.txt",
+		Workers: 1,
+		Scopes: []common.ScopeConfig{{
+			Name: "s", StartQuery: `^BEGIN$`, FinishQuery: `^END$`, SearchQuery: []common.Query{{Pattern: `x`}},
+		}},
+	}, zerolog.Nop())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// scan is cancelled when first scope is closed, so it is stopped in known line
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	summary, err := s.run(ctx, &Events{OnScopeClosed: func(common.ScopeSummary) { cancel() }}, true)
+
+	if err != context.Canceled {
+		t.Errorf("error [%v], want [%v]", err, context.Canceled)
+	}
+	if summary.Incomplete == false {
+		t.Errorf("summary is not incomplete")
+	}
+	if (len(summary.Summary) != 1) || (filepath.Base(summary.Summary[0].FileName) != "a.txt") {
+		t.Fatalf("found %+v, want scopes of [a.txt]", summary.Summary)
+	}
+
+	f := summary.Summary[0]
+	if (f.Aborted == false) || (len(f.Scopes) != 1) || (f.Scopes[0].Started != 1) || (f.Scopes[0].Finished != 3) {
+		t.Errorf("found %+v, want aborted file with scope [1..3]", f)
+	}
+	if (summary.ScanFiles != 1) || (len(summary.FilesWithoutScopes) != 0) {
+		t.Errorf("[%v] scan files and files without scopes %v, want [1] scan file only", summary.ScanFiles, summary.FilesWithoutScopes)
+	}
+}
+
+// TestScan_Captures checks values of named groups of search queries in match lines of summary
+func TestScan_Captures(t *testing.T) {
+
+	content := "BEGIN\nCOMMAND=a\nCOMMAND=b\nEND\nBEGIN\nCOMMAND=a\nx\nEND\n"
+
+	tests := []struct {
+		name  string
+		query common.Query
+	}{
+		{name: "regexp2 engine", query: common.Query{Pattern: `^COMMAND=(?<value>\w+)$`}},
+		{name: "regexp engine", query: common.Query{Pattern: `^COMMAND=(?P<value>\w+)$`, Engine: common.QueryEngineRegexp}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+
+			config := common.ScanConfig{Scopes: []common.ScopeConfig{{
+				Name: "s", StartQuery: `^BEGIN$`, FinishQuery: `^END$`, SearchQuery: []common.Query{tt.query},
+			}}}
+
+			summary := scanFiles(t, config, map[string]string{"a.txt": content, "b.txt": content})
+
+			var got []string
+			for _, f := range summary.Summary {
+				for _, s := range f.Scopes {
+					for _, m := range s.Matches {
+						got = append(got, fmt.Sprintf("%v:%v", m.Index, m.Captures))
+					}
+				}
+			}
+
+			want := "2:map[value:a],3:map[value:b],6:map[value:a],2:map[value:a],3:map[value:b],6:map[value:a]"
+			if strings.Join(got, ",") != want {
+				t.Errorf("captures %v, want %v", strings.Join(got, ","), want)
+			}
+
+			if got := summary.DistinctCaptures("value"); strings.Join(got, ",") != "a,b" {
+				t.Errorf("distinct captures %q, want [a b]", got)
+			}
+		})
+	}
+}
+
+// TestScanner_CaptureNames checks names of named groups of start and search queries of scopes and child scopes
+func TestScanner_CaptureNames(t *testing.T) {
+
+	s, err := New(common.ScanConfig{
+		Folder: ".",
+		Filter: "*.txt",
+		Scopes: []common.ScopeConfig{{
+			Name:        "p",
+			StartQuery:  `^BEGIN (?<tag>\w+)$`,
+			FinishQuery: `^END ${tag}$`,
+			SearchQuery: []common.Query{
+				{Pattern: `(?<value>\d+) (\w+)`},
+				{Pattern: `(?P<line>x)`, Engine: common.QueryEngineRegexp},
+				{Pattern: `a|b`, Engine: common.QueryEngineAhoCorasick},
+			},
+			Scopes: []common.ScopeConfig{{
+				Name:        "c",
+				StartQuery:  `^CHILD$`,
+				FinishQuery: `^DONE$`,
+				SearchQuery: []common.Query{{Pattern: `(?<value>\d+) (?<unit>\w+) ${tag}`}},
+			}},
+		}},
+	}, zerolog.Nop())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := []string{"line", "tag", "unit", "value"}
+	if got := s.CaptureNames(); reflect.DeepEqual(got, want) == false {
+		t.Errorf("CaptureNames [%v], want [%v]", got, want)
+	}
+}
+
+// TestScan_StartFinishTimeout checks that start and finish query (also with placeholders) time out by own timeout
+// when scan has no timeout
+func TestScan_StartFinishTimeout(t *testing.T) {
+
+	line := strings.Repeat("a", 26) + "!"
+
+	tests := []struct {
+		name  string
+		scope common.ScopeConfig
+	}{
+		{
+			name:  "start query",
+			scope: common.ScopeConfig{Name: "s", StartQuery: `^(a+)+$`, FinishQuery: `^END$`, StartQueryTimeout: 1},
+		},
+		{
+			name:  "finish query with placeholder",
+			scope: common.ScopeConfig{Name: "s", StartQuery: `^BEGIN (?<tag>\w+)$`, FinishQuery: `^(a+)+${tag}$`, FinishQueryTimeout: 1},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+
+			tt.scope.SearchQuery = []common.Query{{Pattern: `x`}}
+			summary := scanFiles(t, common.ScanConfig{Scopes: []common.ScopeConfig{tt.scope}}, map[string]string{
+				"a.txt": "BEGIN b\n" + line + "\nEND\n",
+			})
+
+			got := summary.Summary[0].TimedOutLines
+			if reflect.DeepEqual(got, []int{2}) == false {
+				t.Errorf("timed out lines [%v], want [[2]]", got)
+			}
+		})
+	}
+}
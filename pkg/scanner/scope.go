@@ -0,0 +1,202 @@
+package scanner
+
+import (
+	"fmt"
+	"strings"
+
+	common "gorex/pkg/common"
+
+	"github.com/dlclark/regexp2"
+	"github.com/rs/zerolog"
+)
+
+const (
+	notMatchedMark    = " "
+	startScopeMark    = ">"
+	finishScopeMark   = "<"
+	matchedMark       = "*"
+	formatContentHTML = "[%05d|%v][%v]"
+	eofLine           = "[EOF]"
+	parentEndLine     = "[END OF PARENT SCOPE]"
+	regexOpt          = regexp2.Singleline
+)
+
+// -----------------------------------------------------------------------------
+// functions
+// -----------------------------------------------------------------------------
+
+func checkIfBeginScope(line string, rx matcher, scopeIsOpen bool) (bool, error) {
+	m, e := rx.matchString(line)
+	return (scopeIsOpen == false) && (m == true) && (e == nil), e
+}
+
+func checkIfEndScope(line string, rx matcher, scopeIsOpen bool) (bool, error) {
+	m, e := rx.matchString(line)
+	return (scopeIsOpen == true) && (m == true) && (e == nil), e
+}
+
+func checkScopeMatch(line string, rx matcher, scopeIsOpen bool) (bool, error) {
+	m, e := rx.matchString(line)
+	return (scopeIsOpen == true) && (m == true) && (e == nil), e
+}
+
+// findMatchesInScope finds match lines of search queries in closed scope. Accepted is true when scope fulfils
+// search queries (or search expression) and it is reported.
+func findMatchesInScope(scope common.ScopeSummaryWithConfig, compiled *compiledScope, timeouts *fileTimeouts, logger zerolog.Logger) (common.ScopeSummary, bool, error) {
+
+	var result common.ScopeSummary = scope.ScopeSummary
+
+	rx, err := queryMatchers(scope.ScopeConfig.SearchQuery, compiled.search, scope.ScopeSummary.Captures, true, compiled.timeout)
+	if err != nil {
+		return result, false, err
+	}
+
+	exclude, err := queryMatchers(scope.ScopeConfig.ExcludeQuery, compiled.exclude, scope.ScopeSummary.Captures, false, compiled.timeout)
+	if err != nil {
+		return result, false, err
+	}
+
+	logger.Trace().Msgf("Process scope [name=%v] in [%v][%06d..%06d]",
+		scope.ScopeSummary.Name, scope.ScopeSummary.FileName, scope.ScopeSummary.Started, scope.ScopeSummary.Finished)
+
+	if scope.ScopeConfig.SearchExpression != nil {
+		return findExpressionInScope(scope, rx, exclude, timeouts, logger)
+	}
+
+	requiredMatchCount := len(rx)
+	var matchLines []common.MatchLine
+	var matchQueries []int
+	matchesOfRxCounter := make([]int, requiredMatchCount)
+
+	queryMatches := make([]map[int][]common.MatchLine, requiredMatchCount)
+	for j, r := range rx {
+		queryMatches[j] = findQueryMatches(scope.ScopeSummary.Content, scope.ScopeSummary.Started, r, scope.ScopeConfig.SearchQuery[j], timeouts)
+	}
+
+	// match of multiline query is found in its first line
+	for i := range scope.ScopeSummary.Content {
+		for j := range rx {
+			for _, match := range queryMatches[j][i] {
+
+				markMatch(&logger, scope, match)
+				if (scope.ScopeConfig.SearchQueryMode == common.SearchQueryOperatorAny) || (scope.ScopeConfig.SearchQueryMode == common.SearchQueryOperatorAll) || (j == 0) || (matchesOfRxCounter[j-1] > 0) {
+					matchesOfRxCounter[j] = matchesOfRxCounter[j] + 1
+					matchQueries = append(matchQueries, j)
+					matchLines = append(matchLines, match)
+				}
+			}
+		}
+	}
+
+	foundMatchesOfRx := 0
+	for j, k := range matchesOfRxCounter {
+		if scope.ScopeConfig.SearchQuery[j].Accepts(k) {
+			foundMatchesOfRx++
+		}
+	}
+
+	if (scope.ScopeConfig.SearchQueryMode == common.SearchQueryOperatorAny && foundMatchesOfRx > 0) || (foundMatchesOfRx >= requiredMatchCount) {
+
+		// lines of queries which do not fulfil limits are skipped (mode any)
+		var acceptedLines []common.MatchLine
+		for k, j := range matchQueries {
+			if scope.ScopeConfig.SearchQuery[j].Accepts(matchesOfRxCounter[j]) {
+				acceptedLines = append(acceptedLines, matchLines[k])
+			}
+		}
+
+		if (len(acceptedLines) > 0) && (scope.ScopeConfig.AcceptsMatches(len(acceptedLines)) == false) {
+			logger.Trace().Msgf("\t\tSCOPE [%06d..%06d] REJECTED, [%v] lines out of limits [%v..%v]", result.Started, result.Finished, len(acceptedLines), scope.ScopeConfig.MinMatches, scope.ScopeConfig.MaxMatches)
+			return result, false, nil
+		}
+
+		excluded := findExcludedInScope(scope, exclude, timeouts)
+		if excluded != "" {
+			logger.Trace().Msgf("\t\tSCOPE [%06d..%06d] REJECTED, excluded query found in line: %v", result.Started, result.Finished, excluded)
+			return result, false, nil
+		}
+
+		result.Matches = acceptedLines
+		result.Counts = queryCounts(scope.ScopeConfig.SearchQuery, matchesOfRxCounter)
+		result.Reason = acceptReason(scope.ScopeConfig, requiredMatchCount, foundMatchesOfRx, len(acceptedLines))
+		logger.Trace().Msgf("\t\tMATCHES FOUND IN SCOPE [%06d..%06d], lines [%v] of [%v] query", result.Started, result.Finished, len(acceptedLines), foundMatchesOfRx)
+	}
+
+	// scope without match lines is not reported
+	return result, len(result.Matches) > 0, nil
+}
+
+// queryCounts returns number of lines matched by every search query with limits of the query
+func queryCounts(queries []common.Query, counts []int) []common.QueryCount {
+	var result []common.QueryCount
+	for j, q := range queries {
+		result = append(result, common.QueryCount{
+			Query: q.Label(),
+			Count: counts[j],
+			Min:   q.Min,
+			Max:   q.Max,
+		})
+	}
+	return result
+}
+
+// findExcludedInScope returns first line of scope which matches any of exclude queries
+// (empty string when scope does not contain excluded lines). Content of scope contains lines of file only,
+// marks of scope closed without finish line are in html content.
+func findExcludedInScope(scope common.ScopeSummaryWithConfig, exclude []matcher, timeouts *fileTimeouts) string {
+
+	for _, r := range exclude {
+		for i, line := range scope.ScopeSummary.Content {
+			found, err := checkScopeMatch(line, r, true)
+			timeouts.add(i+scope.ScopeSummary.Started, err)
+			if found {
+				return fmt.Sprintf("[%v] %v", i+scope.ScopeSummary.Started, line)
+			}
+		}
+	}
+
+	return ""
+}
+
+// acceptReason describes why scope fulfils search (and exclude) queries
+func acceptReason(sc common.ScopeConfig, queries int, foundQueries int, lines int) string {
+
+	var reason string
+
+	switch sc.SearchQueryMode {
+	case common.SearchQueryOperatorAny:
+		reason = fmt.Sprintf("%v of %v search queries found in %v line(s)", foundQueries, queries, lines)
+	case common.SearchQueryOperatorStrictOrder:
+		reason = fmt.Sprintf("all %v search queries found in strict order in %v line(s)", queries, lines)
+	default:
+		reason = fmt.Sprintf("all %v search queries found in %v line(s)", queries, lines)
+	}
+
+	return reason + constraintsReason(sc)
+}
+
+// constraintsReason describes limits of matches and exclude queries of accepted scope
+func constraintsReason(sc common.ScopeConfig) string {
+	var reason string
+	if (sc.MinMatches > 0) || (sc.MaxMatches > 0) {
+		reason += fmt.Sprintf(", number of lines in limits [%v..%v]", sc.MinMatches, sc.MaxMatches)
+	}
+	if len(sc.ExcludeQuery) > 0 {
+		reason += fmt.Sprintf(", none of %v excluded queries found", len(sc.ExcludeQuery))
+	}
+	return reason
+}
+
+// markMatch marks every line of match in html content of scope
+// (html content contains the same lines as content of scope)
+func markMatch(logger *zerolog.Logger, scope common.ScopeSummaryWithConfig, match common.MatchLine) {
+
+	l := scope.ScopeSummary.ContentAsHTML
+
+	for i := match.Index - scope.ScopeSummary.Started; i <= match.EndIndex-scope.ScopeSummary.Started; i++ {
+		if (i >= 0) && (i < len(l)) {
+			logger.Trace().Msgf("*** Found and modify line %v", l[i])
+			l[i] = strings.Replace(l[i], "|"+notMatchedMark+"][", "|"+matchedMark+"][", 1)
+		}
+	}
+}
@@ -0,0 +1,76 @@
+package scanner
+
+import (
+	"testing"
+
+	common "gorex/pkg/common"
+
+	"github.com/rs/zerolog"
+)
+
+// -----------------------------------------------------------------------------
+// functions
+// -----------------------------------------------------------------------------
+
+// TestFindExcludedInScope checks first excluded line of scope and that marks of scope closed
+// without finish line (html content only) are not matched by exclude queries
+func TestFindExcludedInScope(t *testing.T) {
+
+	tests := []struct {
+		name    string
+		exclude []string
+		scope   common.ScopeSummary
+		want    string
+	}{
+		{
+			name:    "scope closed at end of file",
+			exclude: []string{`EOF`},
+			scope:   common.ScopeSummary{Started: 1, Content: []string{"a", "b"}, ContentAsHTML: []string{"a", "b", eofLine}},
+			want:    "",
+		},
+		{
+			name:    "child scope closed by parent scope",
+			exclude: []string{`END`},
+			scope:   common.ScopeSummary{Started: 2, Content: []string{"x"}, ContentAsHTML: []string{"x", parentEndLine}},
+			want:    "",
+		},
+		{
+			name:    "excluded line",
+			exclude: []string{`^y`},
+			scope:   common.ScopeSummary{Started: 4, Content: []string{"BEGIN", "x", "y", "END"}},
+			want:    "[6] y",
+		},
+		{
+			name:    "first excluded line of first query",
+			exclude: []string{`^z`, `^x`},
+			scope:   common.ScopeSummary{Started: 1, Content: []string{"x", "z"}},
+			want:    "[2] z",
+		},
+		{
+			name:  "no exclude queries",
+			scope: common.ScopeSummary{Started: 1, Content: []string{"x"}},
+			want:  "",
+		},
+	}
+
+	logger := zerolog.Nop()
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+
+			var exclude []matcher
+			for _, pattern := range tt.exclude {
+				m, err := compileMatcher(pattern, "", "", false, 0)
+				if err != nil {
+					t.Fatal(err)
+				}
+				exclude = append(exclude, m)
+			}
+
+			scope := common.ScopeSummaryWithConfig{ScopeSummary: tt.scope}
+			if got := findExcludedInScope(scope, exclude, newFileTimeouts(&logger, "a.txt", 0)); got != tt.want {
+				t.Errorf("excluded line [%v], want [%v]", got, tt.want)
+			}
+		})
+	}
+}
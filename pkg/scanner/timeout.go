@@ -0,0 +1,71 @@
+package scanner
+
+import (
+	"sort"
+
+	"github.com/rs/zerolog"
+)
+
+// -----------------------------------------------------------------------------
+// types
+// -----------------------------------------------------------------------------
+
+// fileTimeouts collects lines of file which could not be matched in time. It is shared by all machines
+// of file (file is processed by single goroutine). Line is index of line passed to machines, it is used
+// by closers which do not know index of line.
+type fileTimeouts struct {
+	logger   *zerolog.Logger
+	fileName string
+	max      int
+	line     int
+	lines    map[int]bool
+	aborted  bool
+}
+
+// -----------------------------------------------------------------------------
+// functions
+// -----------------------------------------------------------------------------
+
+// newFileTimeouts creates timeouts of file which is aborted after max timed out lines (0 means never)
+func newFileTimeouts(logger *zerolog.Logger, fileName string, max int) *fileTimeouts {
+	return &fileTimeouts{
+		logger:   logger,
+		fileName: fileName,
+		max:      max,
+	}
+}
+
+// -----------------------------------------------------------------------------
+// extensions
+// -----------------------------------------------------------------------------
+
+// add records error of matcher (timeout) in line of file. Line is counted once even when many queries time out.
+func (t *fileTimeouts) add(index int, err error) {
+	if err == nil {
+		return
+	}
+
+	if t.lines == nil {
+		t.lines = make(map[int]bool)
+	}
+
+	if t.lines[index] == false {
+		t.lines[index] = true
+		t.logger.Warn().Msgf("Match in [%v][%v] failed: %v", t.fileName, index, err)
+	}
+
+	if (t.max > 0) && (len(t.lines) >= t.max) && (t.aborted == false) {
+		t.aborted = true
+		t.logger.Warn().Msgf("File [%v] aborted after [%v] timed out line(s)", t.fileName, len(t.lines))
+	}
+}
+
+// sortedLines returns timed out lines in ascending order
+func (t *fileTimeouts) sortedLines() []int {
+	var result []int
+	for i := range t.lines {
+		result = append(result, i)
+	}
+	sort.Ints(result)
+	return result
+}
@@ -0,0 +1,293 @@
+// Package stats provides statistics (counts, histograms and top files) of scan summary.
+package stats
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"text/tabwriter"
+
+	common "gorex/pkg/common"
+)
+
+// -----------------------------------------------------------------------------
+// types
+// -----------------------------------------------------------------------------
+
+// FileStats provides number of scopes and match lines found in file
+type FileStats struct {
+	FileName string `json:"fileName"`
+	Scopes   int    `json:"scopes"`
+	Matches  int    `json:"matches"`
+}
+
+// ScopeStats provides totals of scope configuration (by name of scope)
+type ScopeStats struct {
+	Name    string `json:"name"`
+	Files   int    `json:"files"`
+	Scopes  int    `json:"scopes"`
+	Matches int    `json:"matches"`
+}
+
+// ValueCount provides number of occurrences of captured value
+type ValueCount struct {
+	Value string `json:"value"`
+	Count int    `json:"count"`
+}
+
+// CaptureStats provides histogram of values of named group captured in match lines
+type CaptureStats struct {
+	Name     string       `json:"name"`
+	Distinct int          `json:"distinct"`
+	Values   []ValueCount `json:"values"`
+}
+
+// Stats provides statistics of scan summary.
+// Files, NoScopeFiles and Captures values are limited to top N entries (see Compute).
+// NoScopeFiles lists names of files without scopes. NoScopeFilesUnknown is true when the list is not complete
+// because summary does not contain names of files without scopes (it has been saved by older version).
+type Stats struct {
+	Folder              string         `json:"folder"`
+	Filter              string         `json:"filter"`
+	Incomplete          bool           `json:"incomplete,omitempty"`
+	ScanFiles           int            `json:"scanFiles"`
+	FilesWithScopes     int            `json:"filesWithScopes"`
+	FilesWithoutScopes  int            `json:"filesWithoutScopes"`
+	Scopes              int            `json:"scopes"`
+	Matches             int            `json:"matches"`
+	Files               []FileStats    `json:"files"`
+	NoScopeFiles        []string       `json:"noScopeFiles"`
+	NoScopeFilesUnknown bool           `json:"noScopeFilesUnknown,omitempty"`
+	ScopeTotals         []ScopeStats   `json:"scopeTotals"`
+	Captures            []CaptureStats `json:"captures"`
+}
+
+// -----------------------------------------------------------------------------
+// functions
+// -----------------------------------------------------------------------------
+
+// Compute calculates statistics of summary. Top limits number of files and captured values
+// (files with most scopes and most frequent values), 0 means no limit.
+func Compute(summary common.ScanSummary, top int) Stats {
+
+	result := Stats{
+		Folder:          summary.Folder,
+		Filter:          summary.Filter,
+		Incomplete:      summary.Incomplete,
+		ScanFiles:       summary.ScanFiles,
+		FilesWithScopes: summary.FilesWithScopes(),
+	}
+
+	result.FilesWithoutScopes = result.ScanFiles - result.FilesWithScopes
+	if result.FilesWithoutScopes < 0 {
+		result.FilesWithoutScopes = 0
+	}
+
+	scopes := make(map[string]*ScopeStats)
+	var scopeNames []string
+	captures := make(map[string]map[string]int)
+	var captureNames []string
+
+	noScopeFiles := append([]string{}, summary.FilesWithoutScopes...)
+
+	for _, f := range summary.Summary {
+
+		if len(f.Scopes) == 0 {
+			// file with timed out lines (or aborted file) is in summary even without scopes
+			noScopeFiles = append(noScopeFiles, f.FileName)
+		}
+
+		fileStats := FileStats{
+			FileName: f.FileName,
+			Scopes:   len(f.Scopes),
+		}
+		scopeInFile := make(map[string]bool)
+
+		for _, sc := range f.Scopes {
+
+			s, ok := scopes[sc.Name]
+			if ok == false {
+				s = &ScopeStats{Name: sc.Name}
+				scopes[sc.Name] = s
+				scopeNames = append(scopeNames, sc.Name)
+			}
+			if scopeInFile[sc.Name] == false {
+				scopeInFile[sc.Name] = true
+				s.Files++
+			}
+			s.Scopes++
+			s.Matches += len(sc.Matches)
+			fileStats.Matches += len(sc.Matches)
+
+			for _, m := range sc.Matches {
+				for k, v := range m.Captures {
+					values, ok := captures[k]
+					if ok == false {
+						values = make(map[string]int)
+						captures[k] = values
+						captureNames = append(captureNames, k)
+					}
+					values[v]++
+				}
+			}
+		}
+
+		result.Scopes += fileStats.Scopes
+		result.Matches += fileStats.Matches
+		result.Files = append(result.Files, fileStats)
+	}
+
+	sort.SliceStable(result.Files, func(i, j int) bool {
+		if result.Files[i].Scopes != result.Files[j].Scopes {
+			return result.Files[i].Scopes > result.Files[j].Scopes
+		}
+		return result.Files[i].FileName < result.Files[j].FileName
+	})
+	result.Files = limitFiles(result.Files, top)
+
+	sort.Strings(noScopeFiles)
+	result.NoScopeFilesUnknown = len(noScopeFiles) < result.FilesWithoutScopes
+	if (top > 0) && (len(noScopeFiles) > top) {
+		noScopeFiles = noScopeFiles[:top]
+	}
+	result.NoScopeFiles = noScopeFiles
+
+	for _, name := range scopeNames {
+		result.ScopeTotals = append(result.ScopeTotals, *scopes[name])
+	}
+
+	sort.Strings(captureNames)
+	for _, name := range captureNames {
+		c := CaptureStats{
+			Name:     name,
+			Distinct: len(captures[name]),
+		}
+		for v, n := range captures[name] {
+			c.Values = append(c.Values, ValueCount{Value: v, Count: n})
+		}
+		sort.Slice(c.Values, func(i, j int) bool {
+			if c.Values[i].Count != c.Values[j].Count {
+				return c.Values[i].Count > c.Values[j].Count
+			}
+			return c.Values[i].Value < c.Values[j].Value
+		})
+		if (top > 0) && (len(c.Values) > top) {
+			c.Values = c.Values[:top]
+		}
+		result.Captures = append(result.Captures, c)
+	}
+
+	return result
+}
+
+func limitFiles(files []FileStats, top int) []FileStats {
+	if (top > 0) && (len(files) > top) {
+		return files[:top]
+	}
+	return files
+}
+
+// WriteJSON writes statistics in json format
+func (s Stats) WriteJSON(w io.Writer) error {
+	b, err := json.MarshalIndent(s, "", " ")
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(append(b, '\n'))
+	return err
+}
+
+// WriteCSV writes statistics in csv format. Every row contains section, name, value and count.
+func (s Stats) WriteCSV(w io.Writer) error {
+
+	cw := csv.NewWriter(w)
+
+	row := func(section string, name string, value string, count int) {
+		cw.Write([]string{section, name, value, strconv.Itoa(count)})
+	}
+
+	cw.Write([]string{"section", "name", "value", "count"})
+	if s.Incomplete == true {
+		row("summary", "incomplete", "", 1)
+	}
+	row("summary", "scanFiles", "", s.ScanFiles)
+	row("summary", "filesWithScopes", "", s.FilesWithScopes)
+	row("summary", "filesWithoutScopes", "", s.FilesWithoutScopes)
+	row("summary", "scopes", "", s.Scopes)
+	row("summary", "matches", "", s.Matches)
+
+	for _, f := range s.Files {
+		row("file", f.FileName, "scopes", f.Scopes)
+		row("file", f.FileName, "matches", f.Matches)
+	}
+
+	if s.NoScopeFilesUnknown == true {
+		row("summary", "noScopeFilesUnknown", "", 1)
+	}
+	for _, f := range s.NoScopeFiles {
+		row("noScopeFile", f, "", 0)
+	}
+
+	for _, sc := range s.ScopeTotals {
+		row("scope", sc.Name, "files", sc.Files)
+		row("scope", sc.Name, "scopes", sc.Scopes)
+		row("scope", sc.Name, "matches", sc.Matches)
+	}
+
+	for _, c := range s.Captures {
+		row("distinct", c.Name, "", c.Distinct)
+		for _, v := range c.Values {
+			row("capture", c.Name, v.Value, v.Count)
+		}
+	}
+
+	cw.Flush()
+	return cw.Error()
+}
+
+// WriteTable writes statistics as text tables
+func (s Stats) WriteTable(w io.Writer) error {
+
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+
+	fmt.Fprintf(tw, "Folder\t%v\n", s.Folder)
+	fmt.Fprintf(tw, "Filter\t%v\n", s.Filter)
+	if s.Incomplete == true {
+		fmt.Fprintf(tw, "Incomplete\t%v\n", "scan has been cancelled")
+	}
+	fmt.Fprintf(tw, "Scan file(s)\t%v\n", s.ScanFiles)
+	fmt.Fprintf(tw, "File(s) with scopes\t%v\n", s.FilesWithScopes)
+	fmt.Fprintf(tw, "File(s) without scopes\t%v\n", s.FilesWithoutScopes)
+	fmt.Fprintf(tw, "Scope(s)\t%v\n", s.Scopes)
+	fmt.Fprintf(tw, "Match(es)\t%v\n", s.Matches)
+
+	fmt.Fprintf(tw, "\nFILE\tSCOPES\tMATCHES\n")
+	for _, f := range s.Files {
+		fmt.Fprintf(tw, "%v\t%v\t%v\n", f.FileName, f.Scopes, f.Matches)
+	}
+
+	fmt.Fprintf(tw, "\nFILE WITHOUT SCOPES\n")
+	for _, f := range s.NoScopeFiles {
+		fmt.Fprintf(tw, "%v\n", f)
+	}
+	if s.NoScopeFilesUnknown == true {
+		fmt.Fprintf(tw, "%v\n", "(not available, summary has been saved without names of files without scopes)")
+	}
+
+	fmt.Fprintf(tw, "\nSCOPE\tFILES\tSCOPES\tMATCHES\n")
+	for _, sc := range s.ScopeTotals {
+		fmt.Fprintf(tw, "%v\t%v\t%v\t%v\n", sc.Name, sc.Files, sc.Scopes, sc.Matches)
+	}
+
+	for _, c := range s.Captures {
+		fmt.Fprintf(tw, "\nCAPTURE [%v] (%v distinct)\tCOUNT\n", c.Name, c.Distinct)
+		for _, v := range c.Values {
+			fmt.Fprintf(tw, "%v\t%v\n", v.Value, v.Count)
+		}
+	}
+
+	return tw.Flush()
+}
@@ -0,0 +1,42 @@
+package stats
+
+import (
+	"reflect"
+	"testing"
+
+	common "gorex/pkg/common"
+)
+
+// -----------------------------------------------------------------------------
+// functions
+// -----------------------------------------------------------------------------
+
+// TestCompute_FilesWithoutScopes checks that file with timed out lines only is counted as file without scopes
+func TestCompute_FilesWithoutScopes(t *testing.T) {
+
+	summary := common.ScanSummary{
+		ScanFiles: 3,
+		Summary: []common.FileScopeSummary{
+			{FileName: "a.txt", Scopes: []common.ScopeSummary{{Name: "s", Matches: []common.MatchLine{{Index: 1}}}}},
+			{FileName: "b.txt", Scopes: []common.ScopeSummary{}, TimedOutLines: []int{1}},
+		},
+		FilesWithoutScopes: []string{"c.txt"},
+	}
+
+	s := Compute(summary, 0)
+
+	if (s.FilesWithScopes != 1) || (s.FilesWithoutScopes != 2) {
+		t.Errorf("files with scopes [%v], without scopes [%v], want [1] and [2]", s.FilesWithScopes, s.FilesWithoutScopes)
+	}
+	if want := []string{"b.txt", "c.txt"}; (reflect.DeepEqual(s.NoScopeFiles, want) == false) || (s.NoScopeFilesUnknown == true) {
+		t.Errorf("files without scopes %v (unknown %v), want %v", s.NoScopeFiles, s.NoScopeFilesUnknown, want)
+	}
+
+	// summary saved without names of files without scopes
+	summary.FilesWithoutScopes = nil
+	s = Compute(summary, 0)
+
+	if (reflect.DeepEqual(s.NoScopeFiles, []string{"b.txt"}) == false) || (s.NoScopeFilesUnknown == false) {
+		t.Errorf("files without scopes %v (unknown %v), want [b.txt] (unknown true)", s.NoScopeFiles, s.NoScopeFilesUnknown)
+	}
+}
@@ -2,6 +2,7 @@ package utils
 
 import (
 	"fmt"
+	"io"
 	"os"
 	"strings"
 	"time"
@@ -11,14 +12,19 @@ import (
 
 // CreateLogger deliver logger instance
 func CreateLogger(moduleName string, trace bool) zerolog.Logger {
+	return CreateLoggerTo(os.Stdout, moduleName, trace)
+}
+
+// CreateLoggerTo deliver logger instance which writes to out (e.g. stderr when stdout is used by report)
+func CreateLoggerTo(out io.Writer, moduleName string, trace bool) zerolog.Logger {
 
-	fmt.Printf("trace=%v\n", trace)
+	fmt.Fprintf(out, "trace=%v\n", trace)
 
 	if moduleName != "" {
 		moduleName = fmt.Sprintf("› %v ›", moduleName)
 	}
 
-	output := zerolog.ConsoleWriter{Out: os.Stdout, TimeFormat: time.RFC3339}
+	output := zerolog.ConsoleWriter{Out: out, TimeFormat: time.RFC3339}
 	output.NoColor = false
 
 	output.FormatCaller = func(i interface{}) string {